@@ -3,25 +3,35 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
-	"strconv"
 
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
 	"github.com/joho/godotenv"
+	"github.com/parsaabbasian/unispot/backend/internal/config"
 	"github.com/parsaabbasian/unispot/backend/internal/database"
 	"github.com/parsaabbasian/unispot/backend/internal/handlers"
+	"github.com/parsaabbasian/unispot/backend/internal/mail"
 	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"github.com/parsaabbasian/unispot/backend/internal/ratelimit"
+	"github.com/parsaabbasian/unispot/backend/internal/services/digest"
+	"github.com/parsaabbasian/unispot/backend/internal/services/push"
+	"github.com/parsaabbasian/unispot/backend/internal/services/webhook"
 	"github.com/parsaabbasian/unispot/backend/internal/ws"
+	"gorm.io/gorm"
 )
 
 func main() {
 	// Connect to database
 	database.Connect()
 
+	// Pick the rate limit store now that the database is available
+	ratelimit.Configure()
+
 	// Start WebSocket hub
 	go ws.GlobalHub.Run()
 
@@ -41,13 +51,27 @@ func main() {
 	// Start Event Expiry Worker
 	go startEventExpiryWorker()
 
+	// Record hourly WebSocket peak concurrency for admin stats
+	go startConnectionSampler()
+
+	// Match published events to alert rules and send Web Push notifications
+	go push.StartWorker()
+
+	// Email daily and weekly digests
+	go digest.StartScheduler(mail.FromEnv())
+
+	// Forward event lifecycle broadcasts to webhook subscribers
+	ws.GlobalHub.Observe(webhook.Observe)
+	go webhook.StartWorker()
+
 	r := gin.Default()
 
 	// CORS middleware
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Organizer-Token")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Retry-After, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -55,12 +79,45 @@ func main() {
 		c.Next()
 	})
 
-	// API routes
+	// API routes — write endpoints are throttled per IP and per user
+	limiter := ratelimit.Default
 	api := r.Group("/api")
 	{
 		api.GET("/events", handlers.GetEvents)
-		api.POST("/events", handlers.CreateEvent)
-		api.POST("/events/:id/verify", handlers.VerifyEvent)
+		api.GET("/events/history", handlers.GetEventHistory)
+		api.GET("/events/recommended", handlers.GetRecommendedEvents)
+		api.GET("/heatmap", handlers.GetHeatmap)
+		api.GET("/campus/buildings", handlers.GetBuildings)
+		api.GET("/campuses", handlers.GetCampuses)
+		api.GET("/me/preferences", handlers.GetPreferences)
+		api.PUT("/me/preferences", handlers.UpdatePreferences)
+		api.PATCH("/me/preferences", handlers.UpdatePreferences)
+		api.DELETE("/me/preferences", handlers.DeletePreferences)
+		api.GET("/me/alerts", handlers.GetAlertRules)
+		api.POST("/me/alerts", handlers.CreateAlertRule)
+		api.DELETE("/me/alerts/:id", handlers.DeleteAlertRule)
+		api.GET("/digest/unsubscribe", handlers.UnsubscribeDigest)
+		api.POST("/digest/unsubscribe", handlers.UnsubscribeDigest)
+		api.GET("/push/vapid-public-key", handlers.GetVAPIDKey)
+		api.POST("/push/subscriptions", handlers.SubscribePush)
+		api.DELETE("/push/subscriptions", handlers.UnsubscribePush)
+		api.POST("/events", limiter.Route("create_event", ratelimit.Limit{Requests: 5, Per: 10 * time.Minute}), handlers.CreateEvent)
+		api.POST("/events/:id/verify", limiter.Route("verify_event", ratelimit.Limit{Requests: 20, Per: 10 * time.Minute}), handlers.VerifyEvent)
+		api.POST("/events/:id/dispute", limiter.Route("dispute_event", ratelimit.Limit{Requests: 10, Per: 10 * time.Minute}), handlers.DisputeEvent)
+		api.GET("/events/:id/status", handlers.GetEventStatus)
+		api.POST("/events/:id/rsvp", limiter.Route("rsvp", ratelimit.Limit{Requests: 20, Per: time.Minute}), handlers.RSVPEvent)
+		api.DELETE("/events/:id/rsvp", limiter.Route("rsvp", ratelimit.Limit{Requests: 20, Per: time.Minute}), handlers.CancelRSVP)
+		api.POST("/events/:id/checkin", limiter.Route("checkin", ratelimit.Limit{Requests: 10, Per: time.Minute}), handlers.CheckinEvent)
+		api.GET("/events/:id/checkin/token", handlers.GetCheckinToken)
+		api.GET("/events/:id/checkin/qr.png", handlers.GetCheckinQR)
+		api.GET("/events/:id/attendance.csv", handlers.ExportAttendance)
+		api.POST("/events/:id/extend", handlers.ExtendEvent)
+		api.POST("/events/:id/still-happening", limiter.Route("still_happening", ratelimit.Limit{Requests: 10, Per: 10 * time.Minute}), handlers.StillHappening)
+		api.POST("/events/:id/view", limiter.Route("event_view", ratelimit.Limit{Requests: 60, Per: time.Minute}), handlers.RecordEventView)
+		api.GET("/events/:id/comments", handlers.GetComments)
+		api.POST("/events/:id/comments", limiter.Route("comment", ratelimit.Limit{Requests: 10, Per: time.Minute}), handlers.CreateComment)
+		api.POST("/events/:id/reactions", limiter.Route("react", ratelimit.Limit{Requests: 30, Per: time.Minute}), handlers.ReactToEvent)
+		api.DELETE("/events/:id/reactions", limiter.Route("react", ratelimit.Limit{Requests: 30, Per: time.Minute}), handlers.RemoveReaction)
 		api.GET("/health", func(c *gin.Context) {
 			c.JSON(200, gin.H{"status": "ok", "version": "1.0.1"})
 		})
@@ -69,8 +126,23 @@ func main() {
 		admin := api.Group("/admin")
 		{
 			admin.GET("/events", handlers.AdminGetEvents)
+			admin.GET("/stats", handlers.AdminGetStats)
+			admin.GET("/moderation/queue", handlers.AdminModerationQueue)
+			admin.POST("/events/:id/moderate", handlers.AdminModerateEvent)
+			admin.POST("/events/:id/merge", handlers.AdminMergeEvents)
+			admin.POST("/events/:id/restore", handlers.AdminRestoreEvent)
+			admin.POST("/events/:id/extend", handlers.AdminExtendEvent)
 			admin.POST("/events/:id/approve", handlers.AdminToggleApproval)
 			admin.DELETE("/events/:id", handlers.AdminDeleteEvent)
+			admin.GET("/comments", handlers.AdminGetComments)
+			admin.POST("/comments/:id/hide", handlers.AdminHideComment)
+			admin.DELETE("/comments/:id", handlers.AdminDeleteComment)
+			admin.GET("/webhooks", handlers.AdminGetWebhooks)
+			admin.POST("/webhooks", handlers.AdminCreateWebhook)
+			admin.PATCH("/webhooks/:id", handlers.AdminUpdateWebhook)
+			admin.DELETE("/webhooks/:id", handlers.AdminDeleteWebhook)
+			admin.GET("/webhooks/:id/deliveries", handlers.AdminGetWebhookDeliveries)
+			admin.POST("/webhooks/deliveries/:id/redeliver", handlers.AdminRedeliverWebhook)
 		}
 	}
 
@@ -120,20 +192,36 @@ func startDBListener() {
 
 		switch notification.Channel {
 		case "event_deleted":
-			id, _ := strconv.Atoi(notification.Payload)
+			// Payload is the id and category; the row may already be gone
+			var deleted struct {
+				ID       uint   `json:"id"`
+				Category string `json:"category"`
+			}
+			if err := json.Unmarshal([]byte(notification.Payload), &deleted); err != nil {
+				log.Printf("Failed to parse deleted event: %v", err)
+				continue
+			}
 			ws.GlobalHub.BroadcastEvent("delete_event", map[string]interface{}{
-				"id": id,
+				"id":       deleted.ID,
+				"category": deleted.Category,
 			})
-			log.Printf("Broadcasted delete_event for ID: %d", id)
+			log.Printf("Broadcasted delete_event for ID: %d", deleted.ID)
 
 		case "event_updated":
-			// Payload is the full row JSON from row_to_json(NEW)
+			// Payload is the full row JSON plus lat/lng, from notify_event_update
 			var updatedEvent map[string]interface{}
 			if err := json.Unmarshal([]byte(notification.Payload), &updatedEvent); err != nil {
 				log.Printf("Failed to parse updated event: %v", err)
 				continue
 			}
-			ws.GlobalHub.BroadcastEvent("update_event", updatedEvent)
+			var topic struct {
+				ID       uint    `json:"id"`
+				Category string  `json:"category"`
+				Lat      float64 `json:"lat"`
+				Lng      float64 `json:"lng"`
+			}
+			json.Unmarshal([]byte(notification.Payload), &topic)
+			ws.GlobalHub.BroadcastTopic(ws.Topic{EventID: topic.ID, Category: topic.Category, Lat: topic.Lat, Lng: topic.Lng}, "update_event", updatedEvent)
 			log.Printf("Broadcasted update_event: %v", updatedEvent["id"])
 		}
 	}
@@ -145,10 +233,59 @@ func startEventExpiryWorker() {
 
 	for range ticker.C {
 		now := time.Now().UTC()
-		// Delete events where end_time < now
-		result := database.DB.Where("end_time < ?", now).Delete(&models.Event{})
+		// Archive events where end_time < now; the update trigger tells clients to drop them
+		result := database.DB.Model(&models.Event{}).
+			Where("end_time < ? AND archived_at IS NULL", now).
+			Update("archived_at", now)
 		if result.RowsAffected > 0 {
-			log.Printf("Expired %d events", result.RowsAffected)
+			log.Printf("Archived %d events", result.RowsAffected)
+		}
+
+		// Purge archives older than the retention period, along with their child rows
+		cutoff := now.Add(-config.Duration("ARCHIVE_RETENTION", 180*24*time.Hour))
+		if purged := purgeArchivedEvents(cutoff); purged > 0 {
+			log.Printf("Purged %d archived events", purged)
+		}
+	}
+}
+
+// eventChildTables hold rows keyed by event_id that go when their event is purged.
+var eventChildTables = []string{"verifications", "rsvps", "attendances", "disputes", "moderation_logs", "comments", "reactions", "event_views", "push_notifications", "webhook_messages"}
+
+func purgeArchivedEvents(cutoff time.Time) int64 {
+	var purged int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, table := range eventChildTables {
+			if err := tx.Exec(
+				fmt.Sprintf(`DELETE FROM %s WHERE event_id IN (SELECT id FROM events WHERE archived_at < ?)`, table),
+				cutoff,
+			).Error; err != nil {
+				return err
+			}
+		}
+		result := tx.Where("archived_at < ?", cutoff).Delete(&models.Event{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		log.Printf("Failed to purge archived events: %v", err)
+		return 0
+	}
+	return purged
+}
+
+func startConnectionSampler() {
+	ticker := time.NewTicker(1 * time.Minute)
+
+	for range ticker.C {
+		peak := ws.GlobalHub.TakePeak()
+		bucket := time.Now().UTC().Truncate(time.Hour)
+		err := database.DB.Exec(`
+			INSERT INTO connection_samples (bucket_start, peak) VALUES (?, ?)
+			ON CONFLICT (bucket_start) DO UPDATE SET peak = GREATEST(connection_samples.peak, EXCLUDED.peak)
+		`, bucket, peak).Error
+		if err != nil {
+			log.Printf("Failed to record connection sample: %v", err)
 		}
 	}
 }
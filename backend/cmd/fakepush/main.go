@@ -0,0 +1,236 @@
+// Command fakepush is a stand-in Web Push service for local testing. It hands
+// out subscriptions with real keys, decrypts what the API sends to them and
+// keeps it for inspection. Run the API with PUSH_ALLOW_HTTP=true so it accepts
+// the plain-http endpoints.
+//
+//	GET    /subscription             new subscription JSON to POST to /api/push/subscriptions
+//	GET    /subscription?status=410  ...whose endpoint always answers with that status
+//	GET    /subscription?fail_first=2 ...whose endpoint answers 503 twice, then accepts
+//	GET    /received                 pushes received so far
+//	DELETE /received                 forget them
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type subscriber struct {
+	key       *ecdh.PrivateKey
+	auth      []byte
+	status    int // always answer with this, if set
+	failFirst int // answer 503 this many more times
+}
+
+type received struct {
+	SubscriptionID string          `json:"subscription_id"`
+	TTL            string          `json:"ttl"`
+	Topic          string          `json:"topic,omitempty"`
+	Urgency        string          `json:"urgency,omitempty"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	ReceivedAt     time.Time       `json:"received_at"`
+}
+
+type server struct {
+	baseURL string
+
+	mu          sync.Mutex
+	nextID      int
+	subscribers map[string]*subscriber
+	received    []received
+}
+
+func main() {
+	addr := os.Getenv("FAKEPUSH_ADDR")
+	if addr == "" {
+		addr = ":8091"
+	}
+	baseURL := os.Getenv("FAKEPUSH_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost" + addr
+	}
+
+	s := &server{baseURL: baseURL, subscribers: make(map[string]*subscriber)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscription", s.subscribe)
+	mux.HandleFunc("POST /push/{id}", s.push)
+	mux.HandleFunc("GET /received", s.list)
+	mux.HandleFunc("DELETE /received", s.clear)
+
+	log.Printf("Fake push service listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, mux))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *server) subscribe(w http.ResponseWriter, r *http.Request) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	auth := make([]byte, 16)
+	rand.Read(auth)
+
+	sub := &subscriber{key: key, auth: auth}
+	sub.status, _ = strconv.Atoi(r.URL.Query().Get("status"))
+	sub.failFirst, _ = strconv.Atoi(r.URL.Query().Get("fail_first"))
+
+	s.mu.Lock()
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	s.subscribers[id] = sub
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"endpoint": fmt.Sprintf("%s/push/%s", s.baseURL, id),
+		"keys": map[string]string{
+			"p256dh": base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+			"auth":   base64.RawURLEncoding.EncodeToString(auth),
+		},
+	})
+}
+
+func (s *server) push(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subscribers[id]
+	if !ok {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+	if sub.failFirst > 0 {
+		sub.failFirst--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if sub.status != 0 {
+		w.WriteHeader(sub.status)
+		return
+	}
+
+	msg := received{
+		SubscriptionID: id,
+		TTL:            r.Header.Get("TTL"),
+		Topic:          r.Header.Get("Topic"),
+		Urgency:        r.Header.Get("Urgency"),
+		ReceivedAt:     time.Now(),
+	}
+	if plaintext, err := decrypt(sub, body); err != nil {
+		msg.Error = err.Error()
+	} else if json.Valid(plaintext) {
+		msg.Payload = plaintext
+	} else {
+		msg.Payload, _ = json.Marshal(string(plaintext))
+	}
+	s.received = append(s.received, msg)
+	log.Printf("Push for subscription %s: %s%s", id, msg.Payload, msg.Error)
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *server) list(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, s.received)
+}
+
+func (s *server) clear(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.received = nil
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decrypt opens an aes128gcm Web Push message (RFC 8188 and RFC 8291) sent
+// to sub. Only single-record messages are supported, which is all the API sends.
+func decrypt(sub *subscriber, body []byte) ([]byte, error) {
+	if len(body) < 21 {
+		return nil, errors.New("message too short")
+	}
+	salt := body[:16]
+	idLen := int(body[20])
+	if len(body) < 21+idLen {
+		return nil, errors.New("truncated header")
+	}
+	if rs := binary.BigEndian.Uint32(body[16:20]); int(rs) < len(body)-21-idLen {
+		return nil, errors.New("multi-record messages aren't supported")
+	}
+	serverKey, err := ecdh.P256().NewPublicKey(body[21 : 21+idLen])
+	if err != nil {
+		return nil, fmt.Errorf("bad sender key: %w", err)
+	}
+	ciphertext := body[21+idLen:]
+
+	secret, err := sub.key.ECDH(serverKey)
+	if err != nil {
+		return nil, err
+	}
+	prkKey, err := hkdf.Extract(sha256.New, secret, sub.auth)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo := "WebPush: info\x00" + string(sub.key.PublicKey().Bytes()) + string(serverKey.Bytes())
+	ikm, err := hkdf.Expand(sha256.New, prkKey, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+
+	// Strip the padding: the data ends at the last 0x02 delimiter
+	plaintext = bytes.TrimRight(plaintext, "\x00")
+	if len(plaintext) == 0 || plaintext[len(plaintext)-1] != 2 {
+		return nil, errors.New("missing padding delimiter")
+	}
+	return plaintext[:len(plaintext)-1], nil
+}
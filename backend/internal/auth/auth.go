@@ -0,0 +1,70 @@
+// Package auth identifies students from the Supabase session the frontend
+// signs in with. Requests carry the session's access token as
+// "Authorization: Bearer <token>"; anything else is anonymous.
+package auth
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/parsaabbasian/unispot/backend/internal/config"
+)
+
+// Identity is a student whose access token checked out.
+type Identity struct {
+	Email string
+	Name  string
+}
+
+const contextKey = "auth.identity"
+
+type claims struct {
+	Email        string `json:"email"`
+	UserMetadata struct {
+		Name     string `json:"name"`
+		FullName string `json:"full_name"`
+	} `json:"user_metadata"`
+	jwt.RegisteredClaims
+}
+
+// FromRequest returns the identity in the request's access token, or nil if
+// it has none or the token doesn't verify against SUPABASE_JWT_SECRET. The
+// result is cached on the request.
+func FromRequest(c *gin.Context) *Identity {
+	if v, ok := c.Get(contextKey); ok {
+		identity, _ := v.(*Identity)
+		return identity
+	}
+	identity := verify(c.GetHeader("Authorization"))
+	c.Set(contextKey, identity)
+	return identity
+}
+
+func verify(header string) *Identity {
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return nil
+	}
+
+	var cl claims
+	if _, err := jwt.ParseWithClaims(strings.TrimSpace(token), &cl, func(*jwt.Token) (interface{}, error) {
+		return config.Secret("SUPABASE_JWT_SECRET"), nil
+	},
+		jwt.WithValidMethods([]string{"HS256"}),
+		jwt.WithAudience("authenticated"),
+		jwt.WithExpirationRequired(),
+	); err != nil {
+		return nil
+	}
+
+	email := strings.ToLower(strings.TrimSpace(cl.Email))
+	if email == "" {
+		return nil
+	}
+	name := cl.UserMetadata.Name
+	if name == "" {
+		name = cl.UserMetadata.FullName
+	}
+	return &Identity{Email: email, Name: name}
+}
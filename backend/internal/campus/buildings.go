@@ -0,0 +1,86 @@
+// Package campus knows York's campuses and the Keele buildings students can follow.
+package campus
+
+import (
+	"math"
+	"strings"
+)
+
+// Building is a named place on campus. Events within RadiusM metres of it
+// count as being at the building.
+type Building struct {
+	Slug    string  `json:"slug"`
+	Name    string  `json:"name"`
+	Lat     float64 `json:"lat"`
+	Lng     float64 `json:"lng"`
+	RadiusM float64 `json:"radius_m"`
+}
+
+var Buildings = []Building{
+	{Slug: "student-centre", Name: "Student Centre", Lat: 43.7735, Lng: -79.5019, RadiusM: 60},
+	{Slug: "vari-hall", Name: "Vari Hall", Lat: 43.7741, Lng: -79.5035, RadiusM: 50},
+	{Slug: "central-square", Name: "Central Square", Lat: 43.7738, Lng: -79.5030, RadiusM: 50},
+	{Slug: "scott-library", Name: "Scott Library", Lat: 43.7725, Lng: -79.5025, RadiusM: 60},
+	{Slug: "lassonde", Name: "Lassonde (Bergeron Centre)", Lat: 43.7766, Lng: -79.5048, RadiusM: 70},
+	{Slug: "tait-mckenzie", Name: "Tait McKenzie Centre", Lat: 43.7715, Lng: -79.5065, RadiusM: 70},
+	{Slug: "alumni-field", Name: "Alumni Field", Lat: 43.7755, Lng: -79.5075, RadiusM: 90},
+	{Slug: "york-lanes", Name: "York Lanes", Lat: 43.7746, Lng: -79.5003, RadiusM: 60},
+	{Slug: "schulich", Name: "Schulich School of Business", Lat: 43.7714, Lng: -79.4988, RadiusM: 70},
+	{Slug: "osgoode", Name: "Osgoode Hall Law School", Lat: 43.7705, Lng: -79.5045, RadiusM: 60},
+}
+
+// Find looks a building up by slug or by name, ignoring case.
+func Find(key string) (Building, bool) {
+	key = strings.TrimSpace(key)
+	for _, b := range Buildings {
+		if strings.EqualFold(b.Slug, key) || strings.EqualFold(b.Name, key) {
+			return b, true
+		}
+	}
+	return Building{}, false
+}
+
+// Contains reports whether a point lies within the building's radius.
+func (b Building) Contains(lat, lng float64) bool {
+	return distanceMeters(b.Lat, b.Lng, lat, lng) <= b.RadiusM
+}
+
+func distanceMeters(lat1, lng1, lat2, lng2 float64) float64 {
+	const R = 6371000.0 // Earth radius in metres
+	dLat := (lat2 - lat1) * (math.Pi / 180.0)
+	dLng := (lng2 - lng1) * (math.Pi / 180.0)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*(math.Pi/180.0))*math.Cos(lat2*(math.Pi/180.0))*
+			math.Sin(dLng/2)*math.Sin(dLng/2)
+	return R * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// Campus is one of York's campuses. Events within RadiusM metres of its
+// centre belong to it.
+type Campus struct {
+	Slug    string  `json:"slug"`
+	Name    string  `json:"name"`
+	Lat     float64 `json:"lat"`
+	Lng     float64 `json:"lng"`
+	RadiusM float64 `json:"radius_m"`
+}
+
+// DefaultCampus is used when a student hasn't picked a home campus.
+const DefaultCampus = "keele"
+
+// Campuses students can pick as home. Events can only be posted around Keele
+// (see handlers.MaxDistanceKm), so other campuses aren't offered until they can.
+var Campuses = []Campus{
+	{Slug: "keele", Name: "Keele", Lat: 43.7735, Lng: -79.5019, RadiusM: 2500},
+}
+
+// FindCampus looks a campus up by slug or by name, ignoring case.
+func FindCampus(key string) (Campus, bool) {
+	key = strings.TrimSpace(key)
+	for _, c := range Campuses {
+		if strings.EqualFold(c.Slug, key) || strings.EqualFold(c.Name, key) {
+			return c, true
+		}
+	}
+	return Campus{}, false
+}
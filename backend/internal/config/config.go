@@ -0,0 +1,79 @@
+package config
+
+import (
+	"crypto/rand"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// String returns the environment variable key, or def when it is unset.
+func String(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+// Int returns key parsed as an integer, or def when it is unset or invalid.
+func Int(key string, def int) int {
+	if v, err := strconv.Atoi(String(key, "")); err == nil {
+		return v
+	}
+	return def
+}
+
+// Float returns key parsed as a float, or def when it is unset or invalid.
+func Float(key string, def float64) float64 {
+	if v, err := strconv.ParseFloat(String(key, ""), 64); err == nil {
+		return v
+	}
+	return def
+}
+
+// Duration returns key parsed with time.ParseDuration (e.g. "30s", "2h"),
+// or def when it is unset or invalid.
+func Duration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(String(key, "")); err == nil {
+		return v
+	}
+	return def
+}
+
+var (
+	secretsMu sync.Mutex
+	secrets   = map[string][]byte{}
+)
+
+// Secret returns key as signing material. When it is unset a random secret is
+// generated for the life of the process, so signed values won't survive a
+// restart or work across instances.
+func Secret(key string) []byte {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+
+	if s, ok := secrets[key]; ok {
+		return s
+	}
+	s := []byte(String(key, ""))
+	if len(s) == 0 {
+		log.Printf("WARNING: %s not set, using a random per-process secret", key)
+		s = make([]byte, 32)
+		rand.Read(s)
+	}
+	secrets[key] = s
+	return s
+}
+
+// Toronto is the campus time zone the database session runs in, falling back
+// to UTC when the zone database is missing.
+func Toronto() *time.Location {
+	loc, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -33,20 +33,39 @@ func Connect() {
 	// Ensure PostGIS is enabled
 	DB.Exec("CREATE EXTENSION IF NOT EXISTS postgis;")
 
+	// Trigram matching for duplicate title detection
+	DB.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm;")
+
 	// Set session timezone to Toronto
 	DB.Exec("SET TIME ZONE 'America/Toronto';")
 
 	// Automatically create tables
-	err = DB.AutoMigrate(&models.Event{}, &models.RSVP{}, &models.Verification{})
+	err = DB.AutoMigrate(&models.Event{}, &models.User{}, &models.RSVP{}, &models.Verification{}, &models.Attendance{}, &models.Dispute{}, &models.ModerationLog{}, &models.RateLimitBucket{}, &models.ConnectionSample{}, &models.CreatorReputation{}, &models.Comment{}, &models.Reaction{}, &models.EventView{}, &models.UserPreference{}, &models.PushSubscription{}, &models.AlertRule{}, &models.PushNotification{}, &models.WebhookSubscription{}, &models.WebhookDelivery{}, &models.WebhookMessage{})
 	if err != nil {
 		log.Printf("Migration warning: %v", err)
 	}
 
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_events_title_trgm ON events USING gin (title gin_trgm_ops);")
+
+	// Give events from before moderation states existed a status matching their flags
+	DB.Exec(`
+		UPDATE events SET moderation_status = CASE
+			WHEN is_hidden THEN 'hidden'
+			WHEN is_approved THEN 'approved'
+			ELSE 'pending'
+		END
+		WHERE moderation_status IS NULL OR moderation_status = ''
+	`)
+
+	// Events inserted with raw SQL used to skip created_at; their start is
+	// the closest stand-in
+	DB.Exec("UPDATE events SET created_at = start_time WHERE created_at IS NULL")
+
 	// Setup real-time deletion trigger
 	DB.Exec(`
 		CREATE OR REPLACE FUNCTION notify_event_delete() RETURNS trigger AS $$
 		BEGIN
-			PERFORM pg_notify('event_deleted', OLD.id::text);
+			PERFORM pg_notify('event_deleted', json_build_object('id', OLD.id, 'category', OLD.category)::text);
 			RETURN OLD;
 		END;
 		$$ LANGUAGE plpgsql;
@@ -57,11 +76,21 @@ func Connect() {
 		FOR EACH ROW EXECUTE FUNCTION notify_event_delete();
 	`)
 
-	// Setup real-time update trigger — fires on ANY column change
+	// Setup real-time update trigger — fires on ANY column change.
+	// Archiving takes an event off the live map, so clients see it as a delete.
+	// lat/lng ride along so the hub can route the update by viewport; the
+	// creator's email and organizer token hash stay out of the payload.
 	DB.Exec(`
 		CREATE OR REPLACE FUNCTION notify_event_update() RETURNS trigger AS $$
 		BEGIN
-			PERFORM pg_notify('event_updated', row_to_json(NEW)::text);
+			IF NEW.archived_at IS NOT NULL AND OLD.archived_at IS NULL THEN
+				PERFORM pg_notify('event_deleted', json_build_object('id', NEW.id, 'category', NEW.category)::text);
+			ELSE
+				PERFORM pg_notify('event_updated', ((to_jsonb(NEW) - 'creator_email' - 'organizer_token_hash') || jsonb_build_object(
+					'lat', ST_Y(NEW.location::geometry),
+					'lng', ST_X(NEW.location::geometry)
+				))::text);
+			END IF;
 			RETURN NEW;
 		END;
 		$$ LANGUAGE plpgsql;
@@ -78,16 +107,16 @@ func Connect() {
 	if count <= 3 { // If only initial seed or empty, add the premium campus set
 		fmt.Println("Seeding Premium York U campus data...")
 		seedSQL := `
-			INSERT INTO events (title, description, category, location, start_time, end_time, is_approved) 
+			INSERT INTO events (title, description, category, location, start_time, end_time, is_approved, moderation_status, created_at) 
 			VALUES 
-			('York U Hackathon', 'Main Student Centre - Hub', 'Tech', ST_GeogFromText('POINT(-79.5019 43.7735)'), now(), now() + INTERVAL '5 hours', true),
-			('Free Pizza @ Lassonde', 'Bergeron Centre Lobby - First come first serve!', 'Food', ST_GeogFromText('POINT(-79.5048 43.7766)'), now(), now() + INTERVAL '2 hours', true),
-			('Finals Prep: Discrete Math', 'Scott Library 2nd Floor - Bring coffee.', 'Study', ST_GeogFromText('POINT(-79.5025 43.7725)'), now(), now() + INTERVAL '4 hours', true),
-			('Intramural Soccer', 'Alumni Field - Need 2 more players!', 'Sports', ST_GeogFromText('POINT(-79.5075 43.7755)'), now(), now() + INTERVAL '3 hours', true),
-			('Winter Coat Sale', 'Central Square - Up to 70%% off!', 'Sale', ST_GeogFromText('POINT(-79.5030 43.7738)'), now(), now() + INTERVAL '6 hours', true),
-			('Tait McKenzie Gym Blast', 'Full body workout - Open to all levels.', 'Sports', ST_GeogFromText('POINT(-79.5065 43.7715)'), now(), now() + INTERVAL '1 hour', true),
-			('Evening Safety Patrol', 'Walking from Vari Hall to Quad.', 'Safety', ST_GeogFromText('POINT(-79.5035 43.7742)'), now(), now() + INTERVAL '2 hours', true),
-			('Used Textbook Swap', 'Student Centre - Mostly CS/Eng books.', 'Sale', ST_GeogFromText('POINT(-79.5015 43.7732)'), now(), now() + INTERVAL '8 hours', true);
+			('York U Hackathon', 'Main Student Centre - Hub', 'Tech', ST_GeogFromText('POINT(-79.5019 43.7735)'), now(), now() + INTERVAL '5 hours', true, 'approved', now()),
+			('Free Pizza @ Lassonde', 'Bergeron Centre Lobby - First come first serve!', 'Food', ST_GeogFromText('POINT(-79.5048 43.7766)'), now(), now() + INTERVAL '2 hours', true, 'approved', now()),
+			('Finals Prep: Discrete Math', 'Scott Library 2nd Floor - Bring coffee.', 'Study', ST_GeogFromText('POINT(-79.5025 43.7725)'), now(), now() + INTERVAL '4 hours', true, 'approved', now()),
+			('Intramural Soccer', 'Alumni Field - Need 2 more players!', 'Sports', ST_GeogFromText('POINT(-79.5075 43.7755)'), now(), now() + INTERVAL '3 hours', true, 'approved', now()),
+			('Winter Coat Sale', 'Central Square - Up to 70%% off!', 'Sale', ST_GeogFromText('POINT(-79.5030 43.7738)'), now(), now() + INTERVAL '6 hours', true, 'approved', now()),
+			('Tait McKenzie Gym Blast', 'Full body workout - Open to all levels.', 'Sports', ST_GeogFromText('POINT(-79.5065 43.7715)'), now(), now() + INTERVAL '1 hour', true, 'approved', now()),
+			('Evening Safety Patrol', 'Walking from Vari Hall to Quad.', 'Safety', ST_GeogFromText('POINT(-79.5035 43.7742)'), now(), now() + INTERVAL '2 hours', true, 'approved', now()),
+			('Used Textbook Swap', 'Student Centre - Mostly CS/Eng books.', 'Sale', ST_GeogFromText('POINT(-79.5015 43.7732)'), now(), now() + INTERVAL '8 hours', true, 'approved', now());
 		`
 		DB.Exec(seedSQL)
 	}
@@ -1,57 +1,60 @@
 package handlers
 
 import (
-	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lib/pq"
 	"github.com/parsaabbasian/unispot/backend/internal/database"
 	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"github.com/parsaabbasian/unispot/backend/internal/services/moderation"
 )
 
+// adminEvent is an event as admins see it, with its creator's email.
+type adminEvent struct {
+	models.Event
+	CreatorEmail string `json:"creator_email"`
+}
+
 func AdminGetEvents(c *gin.Context) {
 	var results []struct {
 		models.Event
 		LocationText string         `gorm:"column:location_text"`
 		Verifiers    pq.StringArray `gorm:"column:verifier_names"`
+		Reputation   *float64       `gorm:"column:creator_reputation"`
 	}
 
 	query := `
 		SELECT 
 			e.*, 
 			ST_AsText(e.location) as location_text, 
-			COALESCE(array_agg(v.user_name) FILTER (WHERE v.user_name IS NOT NULL), '{}') as verifier_names
+			COALESCE(array_agg(v.user_name) FILTER (WHERE v.user_name IS NOT NULL), '{}') as verifier_names,
+			(SELECT cr.score FROM creator_reputations cr WHERE cr.email = LOWER(e.creator_email)) as creator_reputation
 		FROM events e
 		LEFT JOIN verifications v ON e.id = v.event_id
+		WHERE ? OR e.archived_at IS NULL
 		GROUP BY e.id, location_text
 		ORDER BY e.id DESC
 	`
 
-	if err := database.DB.Raw(query).Scan(&results).Error; err != nil {
+	includeArchived := c.Query("include_archived") == "true"
+	if err := database.DB.Raw(query, includeArchived).Scan(&results).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	events := make([]models.Event, len(results))
+	events := make([]adminEvent, len(results))
 	for i, res := range results {
-		events[i] = res.Event
+		events[i] = adminEvent{res.Event, res.CreatorEmail}
 		events[i].Verifiers = []string(res.Verifiers)
-
-		// Parse POINT(lng lat)
-		loc := strings.TrimPrefix(res.LocationText, "POINT(")
-		loc = strings.TrimSuffix(loc, ")")
-		parts := strings.Split(loc, " ")
-		if len(parts) == 2 {
-			fmt.Sscanf(parts[0], "%f", &events[i].Longitude)
-			fmt.Sscanf(parts[1], "%f", &events[i].Latitude)
-		}
+		events[i].CreatorReputation = res.Reputation
+		setLatLng(&events[i].Event, res.LocationText)
 	}
 
 	c.JSON(http.StatusOK, events)
 }
 
+// AdminToggleApproval publishes a pending event, or takes a published one back to pending.
 func AdminToggleApproval(c *gin.Context) {
 	id := c.Param("id")
 	var event models.Event
@@ -60,13 +63,18 @@ func AdminToggleApproval(c *gin.Context) {
 		return
 	}
 
-	event.IsApproved = !event.IsApproved
-	if err := database.DB.Save(&event).Error; err != nil {
+	status := moderation.Approved
+	if event.ModerationStatus == moderation.Approved {
+		status = moderation.Pending
+	}
+
+	updated, err := moderation.Transition(event.ID, status, "", adminActor(c))
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, event)
+	c.JSON(http.StatusOK, updated)
 }
 
 func AdminDeleteEvent(c *gin.Context) {
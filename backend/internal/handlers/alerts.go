@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parsaabbasian/unispot/backend/internal/campus"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"github.com/parsaabbasian/unispot/backend/internal/services/push"
+	"gorm.io/gorm/clause"
+)
+
+// Most alert rules one user can have.
+const maxAlertRules = 20
+
+type PushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint" binding:"required"`
+	Keys     struct {
+		P256dh string `json:"p256dh" binding:"required"`
+		Auth   string `json:"auth" binding:"required"`
+	} `json:"keys" binding:"required"`
+}
+
+type PushUnsubscribeRequest struct {
+	Endpoint string `json:"endpoint" binding:"required"`
+}
+
+// AlertRuleRequest describes an alert, e.g. Food within 300 m of Scott
+// Library between 11:00 and 14:00. Give either building or lat/lng.
+type AlertRuleRequest struct {
+	Category  string   `json:"category"`
+	Building  string   `json:"building"`
+	Latitude  *float64 `json:"lat"`
+	Longitude *float64 `json:"lng"`
+	RadiusM   float64  `json:"radius_m" binding:"required,gt=0,lte=2500"`
+	StartTime string   `json:"start_time"` // HH:MM, Toronto time
+	EndTime   string   `json:"end_time"`
+}
+
+// GetVAPIDKey returns the public key browsers need to subscribe.
+func GetVAPIDKey(c *gin.Context) {
+	public, _ := push.VAPIDKeys()
+	c.JSON(http.StatusOK, gin.H{"public_key": public})
+}
+
+// SubscribePush saves the browser's push subscription for the signed-in user.
+// Re-subscribing an endpoint takes it over and revives it if it had expired.
+func SubscribePush(c *gin.Context) {
+	user, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	var req PushSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	endpoint, err := url.Parse(req.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "https" && !(endpoint.Scheme == "http" && push.AllowHTTPEndpoints())) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "endpoint must be an https URL"})
+		return
+	}
+
+	sub := models.PushSubscription{
+		UserID:   user.ID,
+		Endpoint: req.Endpoint,
+		P256dh:   req.Keys.P256dh,
+		Auth:     req.Keys.Auth,
+	}
+	if err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "endpoint"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "p256dh", "auth", "expired_at"}),
+	}).Create(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, sub)
+}
+
+func UnsubscribePush(c *gin.Context) {
+	user, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	var req PushUnsubscribeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var sub models.PushSubscription
+	if err := database.DB.Where("endpoint = ? AND user_id = ?", req.Endpoint, user.ID).First(&sub).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return
+	}
+	if err := database.DB.Where("subscription_id = ?", sub.ID).Delete(&models.PushNotification{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.DB.Delete(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unsubscribed"})
+}
+
+// parseClock turns HH:MM into minutes after midnight.
+func parseClock(field, v string) (*int, error) {
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be HH:MM", field)
+	}
+	minute := t.Hour()*60 + t.Minute()
+	return &minute, nil
+}
+
+func GetAlertRules(c *gin.Context) {
+	user, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	var results []struct {
+		models.AlertRule
+		LocationText string `gorm:"column:location_text"`
+	}
+	if err := database.DB.Raw(`
+		SELECT r.*, ST_AsText(r.location) as location_text
+		FROM alert_rules r WHERE r.user_id = ?
+		ORDER BY r.created_at
+	`, user.ID).Scan(&results).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	rules := make([]models.AlertRule, len(results))
+	for i, res := range results {
+		rules[i] = res.AlertRule
+		rules[i].Latitude, rules[i].Longitude = parsePoint(res.LocationText)
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+func CreateAlertRule(c *gin.Context) {
+	user, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	var req AlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := models.AlertRule{UserID: user.ID, Category: req.Category, RadiusM: req.RadiusM}
+	switch {
+	case req.Building != "":
+		b, ok := campus.Find(req.Building)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown building %q", req.Building)})
+			return
+		}
+		rule.Building = b.Slug
+		rule.Latitude, rule.Longitude = b.Lat, b.Lng
+	case req.Latitude != nil && req.Longitude != nil:
+		rule.Latitude, rule.Longitude = *req.Latitude, *req.Longitude
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Give either building or lat and lng"})
+		return
+	}
+	if calculateDistance(rule.Latitude, rule.Longitude, YorkULat, YorkULng) > MaxDistanceKm {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Alerts must be centred on campus"})
+		return
+	}
+
+	var err error
+	if rule.StartMinute, err = parseClock("start_time", req.StartTime); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if rule.EndMinute, err = parseClock("end_time", req.EndTime); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (rule.StartMinute == nil) != (rule.EndMinute == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Give both start_time and end_time, or neither"})
+		return
+	}
+
+	var count int64
+	database.DB.Model(&models.AlertRule{}).Where("user_id = ?", user.ID).Count(&count)
+	if count >= maxAlertRules {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("You can have at most %d alerts", maxAlertRules)})
+		return
+	}
+
+	if err := database.DB.Raw(`
+		INSERT INTO alert_rules (user_id, category, building, location, radius_m, start_minute, end_minute, created_at)
+		VALUES (?, ?, ?, ST_GeogFromText(?), ?, ?, ?, now())
+		RETURNING id, created_at
+	`, rule.UserID, rule.Category, rule.Building, fmt.Sprintf("POINT(%f %f)", rule.Longitude, rule.Latitude),
+		rule.RadiusM, rule.StartMinute, rule.EndMinute).Row().Scan(&rule.ID, &rule.CreatedAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+func DeleteAlertRule(c *gin.Context) {
+	user, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	result := database.DB.Where("id = ? AND user_id = ?", c.Param("id"), user.ID).Delete(&models.AlertRule{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert deleted"})
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/parsaabbasian/unispot/backend/internal/config"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"github.com/parsaabbasian/unispot/backend/internal/ws"
+)
+
+// historyMaxRange caps how much history one request can pull.
+const historyMaxRange = 366 * 24 * time.Hour
+
+type RestoreEventRequest struct {
+	Duration float64 `json:"duration_hours"` // hours from now; required if the event has already ended
+}
+
+// parseTimeParam accepts RFC 3339 timestamps or plain YYYY-MM-DD dates (Toronto time).
+func parseTimeParam(c *gin.Context, name string, def time.Time) (time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return def, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", v, config.Toronto()); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp or YYYY-MM-DD date", name)
+}
+
+// parseRange reads the from/to query params, defaulting to the last 30 days.
+func parseRange(c *gin.Context) (time.Time, time.Time, bool) {
+	now := time.Now()
+	to, err := parseTimeParam(c, "to", now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return time.Time{}, time.Time{}, false
+	}
+	from, err := parseTimeParam(c, "from", to.AddDate(0, 0, -30))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return time.Time{}, time.Time{}, false
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+// GetEventHistory lists published events, live or archived, that overlap the from/to range.
+func GetEventHistory(c *gin.Context) {
+	from, to, ok := parseRange(c)
+	if !ok {
+		return
+	}
+	if to.Sub(from) > historyMaxRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "History range can be at most a year"})
+		return
+	}
+
+	var rawEvents []struct {
+		models.Event
+		Loc       string         `gorm:"column:location_text"`
+		Verifiers pq.StringArray `gorm:"column:verifier_names"`
+	}
+
+	query := `
+		SELECT
+			e.id, e.title, e.description, e.category, ST_AsText(e.location) as location_text,
+			e.start_time, e.end_time, e.verified_count, e.creator_name, e.is_approved, e.archived_at, e.created_at,
+			COALESCE(array_agg(v.user_name) FILTER (WHERE v.user_name IS NOT NULL), '{}') as verifier_names
+		FROM events e
+		LEFT JOIN verifications v ON e.id = v.event_id
+		WHERE e.moderation_status = 'approved'
+		  AND e.start_time < ?
+		  AND e.end_time > ?
+		GROUP BY e.id, location_text
+		ORDER BY e.start_time DESC
+	`
+
+	if err := database.DB.Raw(query, to, from).Scan(&rawEvents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	events := make([]models.Event, len(rawEvents))
+	for i, re := range rawEvents {
+		events[i] = re.Event
+		events[i].Location = re.Loc
+		events[i].Verifiers = []string(re.Verifiers)
+		setLatLng(&events[i], re.Loc)
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// AdminRestoreEvent puts an archived event back on the live map. Events that
+// have already ended need a new duration.
+func AdminRestoreEvent(c *gin.Context) {
+	var req RestoreEventRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var event models.Event
+	if err := database.DB.Where("archived_at IS NOT NULL").First(&event, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Archived event not found"})
+		return
+	}
+
+	updates := map[string]interface{}{"archived_at": nil}
+	if req.Duration > 0 {
+		updates["end_time"] = time.Now().UTC().Add(time.Duration(req.Duration * float64(time.Hour)))
+	} else if event.EndTime.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This event has ended; give duration_hours to restore it"})
+		return
+	}
+
+	if err := database.DB.Model(&event).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	restored, err := loadEvent(event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if restored.IsApproved && !restored.IsHidden {
+		ws.GlobalHub.BroadcastTopic(ws.EventTopic(restored), "new_event", restored)
+	}
+
+	c.JSON(http.StatusOK, restored)
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parsaabbasian/unispot/backend/internal/config"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"github.com/parsaabbasian/unispot/backend/internal/services/checkin"
+	"github.com/skip2/go-qrcode"
+)
+
+// Attendees may check in a little before the advertised start.
+const checkinEarlyWindow = 15 * time.Minute
+
+type CheckinRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// organizerTokenHeader carries the token returned to an event's creator.
+// Creator emails are easy to guess, so organizer endpoints don't go by them.
+const organizerTokenHeader = "X-Organizer-Token"
+
+// newOrganizerToken returns a random token for an event's creator and the
+// hash that is stored in its place.
+func newOrganizerToken() (string, string) {
+	b := make([]byte, 24)
+	rand.Read(b)
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashOrganizerToken(token)
+}
+
+func hashOrganizerToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadOrganizerEvent loads the event in the :id param and makes sure the
+// request carries the organizer token handed out when it was created.
+func loadOrganizerEvent(c *gin.Context) (*models.Event, bool) {
+	token := c.GetHeader(organizerTokenHeader)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organizer token required"})
+		return nil, false
+	}
+
+	var event models.Event
+	if err := database.DB.First(&event, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, false
+	}
+	if event.OrganizerTokenHash == "" || subtle.ConstantTimeCompare([]byte(hashOrganizerToken(token)), []byte(event.OrganizerTokenHash)) != 1 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the organizer can do that"})
+		return nil, false
+	}
+	return &event, true
+}
+
+func checkinURL(eventID uint, token string) string {
+	base := config.String("FRONTEND_URL", "https://yorkunispot.vercel.app")
+	return fmt.Sprintf("%s/checkin?event=%d&token=%s", strings.TrimRight(base, "/"), eventID, url.QueryEscape(token))
+}
+
+func GetCheckinToken(c *gin.Context) {
+	event, ok := loadOrganizerEvent(c)
+	if !ok {
+		return
+	}
+
+	token, expiresAt := checkin.Issue(event.ID, time.Now())
+	c.JSON(http.StatusOK, gin.H{
+		"token":       token,
+		"expires_at":  expiresAt,
+		"checkin_url": checkinURL(event.ID, token),
+	})
+}
+
+func GetCheckinQR(c *gin.Context) {
+	event, ok := loadOrganizerEvent(c)
+	if !ok {
+		return
+	}
+
+	token, expiresAt := checkin.Issue(event.ID, time.Now())
+	png, err := qrcode.Encode(checkinURL(event.ID, token), qrcode.Medium, 512)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The code rotates, so the organizer's screen must keep refetching it
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Checkin-Expires-At", expiresAt.Format(time.RFC3339))
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+func CheckinEvent(c *gin.Context) {
+	user, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	var req CheckinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var event models.Event
+	if err := database.DB.Scopes(liveEvents).First(&event, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	now := time.Now()
+	if now.Before(event.StartTime.Add(-checkinEarlyWindow)) || now.After(event.EndTime) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Check-in is only open while the event is running"})
+		return
+	}
+	if err := checkin.Validate(event.ID, req.Token, now); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	attendance := models.Attendance{
+		EventID:     event.ID,
+		UserID:      user.ID,
+		UserName:    user.Name,
+		UserEmail:   user.Email,
+		IPAddress:   c.ClientIP(),
+		CheckedInAt: now.UTC(),
+	}
+	if err := database.DB.Create(&attendance).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already checked in"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":       "Checked in successfully",
+		"checked_in_at": attendance.CheckedInAt,
+	})
+}
+
+func ExportAttendance(c *gin.Context) {
+	event, ok := loadOrganizerEvent(c)
+	if !ok {
+		return
+	}
+
+	var attendance []models.Attendance
+	if err := database.DB.Where("event_id = ?", event.ID).Order("checked_in_at ASC").Find(&attendance).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="event-%d-attendance.csv"`, event.ID))
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"name", "email", "checked_in_at"})
+	for _, a := range attendance {
+		w.Write([]string{a.UserName, a.UserEmail, a.CheckedInAt.In(config.Toronto()).Format(time.RFC3339)})
+	}
+	w.Flush()
+}
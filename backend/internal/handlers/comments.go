@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"github.com/parsaabbasian/unispot/backend/internal/services/safety"
+	"github.com/parsaabbasian/unispot/backend/internal/ws"
+)
+
+const (
+	commentPageSize    = 30
+	commentMaxPageSize = 100
+)
+
+type CreateCommentRequest struct {
+	Body string `json:"body" binding:"required,max=500"`
+}
+
+type HideCommentRequest struct {
+	Hidden *bool  `json:"hidden" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// loadPublicEvent loads the event in the :id param if it is on the public map.
+func loadPublicEvent(c *gin.Context) (*models.Event, bool) {
+	var event models.Event
+	if err := database.DB.Scopes(publicEvents).First(&event, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, false
+	}
+	return &event, true
+}
+
+// GetComments returns an event's visible comments, newest first. Pass the
+// returned next_before as before to fetch the next page.
+func GetComments(c *gin.Context) {
+	event, ok := loadPublicEvent(c)
+	if !ok {
+		return
+	}
+
+	limit := commentPageSize
+	if v := c.Query("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > commentMaxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+			return
+		}
+		limit = parsed
+	}
+
+	query := database.DB.Where("event_id = ? AND is_hidden = ?", event.ID, false)
+	if v := c.Query("before"); v != "" {
+		before, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "before must be a comment id"})
+			return
+		}
+		query = query.Where("id < ?", before)
+	}
+
+	// One extra row tells us whether another page exists
+	var comments []models.Comment
+	if err := query.Order("id DESC").Limit(limit + 1).Find(&comments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var nextBefore *uint
+	if len(comments) > limit {
+		comments = comments[:limit]
+		nextBefore = &comments[limit-1].ID
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"comments":    comments,
+		"next_before": nextBefore,
+	})
+}
+
+func CreateComment(c *gin.Context) {
+	user, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	var req CreateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	body := strings.TrimSpace(req.Body)
+	if body == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Comment can't be empty"})
+		return
+	}
+
+	event, ok := loadPublicEvent(c)
+	if !ok {
+		return
+	}
+
+	// Same content checks as event submissions; held comments wait for an admin
+	screening := safety.Run(safety.Submission{Description: body})
+	if screening.Verdict == safety.Reject {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Comment was rejected by content checks", "reasons": screening.Reasons})
+		return
+	}
+
+	comment := models.Comment{
+		EventID:    event.ID,
+		UserID:     user.ID,
+		AuthorName: user.Name,
+		Body:       body,
+		IPAddress:  c.ClientIP(),
+	}
+	if screening.Verdict == safety.Hold {
+		comment.IsHidden = true
+		comment.HideReason = "Held for review: " + strings.Join(screening.Reasons, "; ")
+	}
+	if err := database.DB.Create(&comment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !comment.IsHidden {
+		ws.GlobalHub.BroadcastToEventWatchers(event.ID, "new_comment", comment)
+	}
+
+	c.JSON(http.StatusCreated, comment)
+}
+
+// AdminGetComments lists comments for review, newest first, with the title
+// of the event each is on. ?hidden=true shows held and hidden comments,
+// ?hidden=false visible ones; ?event_id narrows to one event. Page with
+// before and limit as in GetComments.
+func AdminGetComments(c *gin.Context) {
+	limit := commentPageSize
+	if v := c.Query("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > commentMaxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+			return
+		}
+		limit = parsed
+	}
+
+	query := database.DB.Table("comments").
+		Select("comments.*, events.title as event_title").
+		Joins("JOIN events ON events.id = comments.event_id")
+	if v := c.Query("hidden"); v != "" {
+		hidden, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "hidden must be true or false"})
+			return
+		}
+		query = query.Where("comments.is_hidden = ?", hidden)
+	}
+	if v := c.Query("event_id"); v != "" {
+		eventID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "event_id must be an event id"})
+			return
+		}
+		query = query.Where("comments.event_id = ?", eventID)
+	}
+	if v := c.Query("before"); v != "" {
+		before, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "before must be a comment id"})
+			return
+		}
+		query = query.Where("comments.id < ?", before)
+	}
+
+	var comments []struct {
+		models.Comment
+		EventTitle string `json:"event_title"`
+	}
+	if err := query.Order("comments.id DESC").Limit(limit + 1).Scan(&comments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var nextBefore *uint
+	if len(comments) > limit {
+		comments = comments[:limit]
+		nextBefore = &comments[limit-1].ID
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"comments":    comments,
+		"next_before": nextBefore,
+	})
+}
+
+// AdminHideComment hides a comment from the public thread, or shows it again.
+func AdminHideComment(c *gin.Context) {
+	var req HideCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var comment models.Comment
+	if err := database.DB.First(&comment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+
+	comment.IsHidden = *req.Hidden
+	comment.HideReason = ""
+	if comment.IsHidden {
+		comment.HideReason = req.Reason
+	}
+	if err := database.DB.Model(&comment).Updates(map[string]interface{}{
+		"is_hidden":   comment.IsHidden,
+		"hide_reason": comment.HideReason,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if comment.IsHidden {
+		ws.GlobalHub.BroadcastToEventWatchers(comment.EventID, "remove_comment", gin.H{"id": comment.ID, "event_id": comment.EventID})
+	} else {
+		ws.GlobalHub.BroadcastToEventWatchers(comment.EventID, "new_comment", comment)
+	}
+
+	c.JSON(http.StatusOK, comment)
+}
+
+func AdminDeleteComment(c *gin.Context) {
+	var comment models.Comment
+	if err := database.DB.First(&comment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	if err := database.DB.Delete(&comment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ws.GlobalHub.BroadcastToEventWatchers(comment.EventID, "remove_comment", gin.H{"id": comment.ID, "event_id": comment.EventID})
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+}
@@ -1,26 +1,67 @@
 package handlers
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"math"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/parsaabbasian/unispot/backend/internal/config"
 	"github.com/parsaabbasian/unispot/backend/internal/database"
 	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"github.com/parsaabbasian/unispot/backend/internal/ratelimit"
+	"github.com/parsaabbasian/unispot/backend/internal/services/moderation"
+	"github.com/parsaabbasian/unispot/backend/internal/services/push"
+	"github.com/parsaabbasian/unispot/backend/internal/services/reputation"
+	"github.com/parsaabbasian/unispot/backend/internal/services/safety"
 	"github.com/parsaabbasian/unispot/backend/internal/ws"
 )
 
 type CreateEventRequest struct {
-	Title        string  `json:"title" binding:"required"`
-	Description  string  `json:"description"`
-	Category     string  `json:"category" binding:"required"`
-	Latitude     float64 `json:"lat" binding:"required"`
-	Longitude    float64 `json:"lng" binding:"required"`
-	Duration     float64 `json:"duration_hours" binding:"required"` // hours from now
-	CreatorName  string  `json:"creator_name"`
-	CreatorEmail string  `json:"creator_email"`
+	Title       string  `json:"title" binding:"required"`
+	Description string  `json:"description"`
+	Category    string  `json:"category" binding:"required"`
+	Latitude    float64 `json:"lat" binding:"required"`
+	Longitude   float64 `json:"lng" binding:"required"`
+	Duration    float64 `json:"duration_hours" binding:"required"` // hours from now
+	CreatorName string  `json:"creator_name"`
+	Capacity    *int    `json:"capacity" binding:"omitempty,min=1"` // optional spot limit
+	// Set after a 409 to post anyway, or to verify one of the suggested duplicates instead
+	Force            bool  `json:"force"`
+	VerifyExistingID *uint `json:"verify_existing_id"`
+}
+
+// createdEvent is only returned to the event's creator, who needs the
+// organizer token for check-ins, extensions and moderation status.
+type createdEvent struct {
+	models.Event
+	OrganizerToken string `json:"organizer_token"`
+}
+
+// scraperEmail is the account scraped events are posted under. Only
+// requests carrying SCRAPER_TOKEN may post as it.
+const scraperEmail = "scraper_bot@unispot.ca"
+
+// eventCreator resolves who is posting: the scraper when the request has
+// SCRAPER_TOKEN as its bearer token, otherwise the user whose Supabase
+// session verified, or "" for anonymous posts. Reputation follows this,
+// never a header or the request body.
+func eventCreator(c *gin.Context) (string, bool) {
+	if token := config.String("SCRAPER_TOKEN", ""); token != "" &&
+		subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte("Bearer "+token)) == 1 {
+		return scraperEmail, true
+	}
+	if user := currentUser(c); user != nil {
+		if user.Email == scraperEmail {
+			c.JSON(http.StatusForbidden, gin.H{"error": "That account is reserved for scraped events"})
+			return "", false
+		}
+		return user.Email, true
+	}
+	return "", true
 }
 
 const (
@@ -47,6 +88,11 @@ func CreateEvent(c *gin.Context) {
 		return
 	}
 
+	creatorEmail, ok := eventCreator(c)
+	if !ok {
+		return
+	}
+
 	// Geofencing Check
 	dist := calculateDistance(req.Latitude, req.Longitude, YorkULat, YorkULng)
 	if dist > MaxDistanceKm {
@@ -54,43 +100,116 @@ func CreateEvent(c *gin.Context) {
 		return
 	}
 
+	if req.VerifyExistingID != nil {
+		verifyEvent(c, *req.VerifyExistingID, VerifyEventRequest{
+			UserName:  req.CreatorName,
+			UserEmail: creatorEmail,
+			Latitude:  &req.Latitude,
+			Longitude: &req.Longitude,
+		})
+		return
+	}
+
+	startTime := time.Now().UTC().Add(-1 * time.Minute)
+	endTime := time.Now().UTC().Add(time.Duration(req.Duration * float64(time.Hour)))
+	// Longer requests are cut to the category limit that extensions keep to
+	if limit := startTime.Add(maxDuration(req.Category)); endTime.After(limit) {
+		endTime = limit
+	}
+
+	if !req.Force {
+		duplicates, err := findDuplicates(req, startTime, endTime)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(duplicates) > 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":      "This looks like an event that is already on the map",
+				"duplicates": duplicates,
+			})
+			return
+		}
+	}
+
 	// Create PostGIS Point string
 	locationStr := fmt.Sprintf("POINT(%f %f)", req.Longitude, req.Latitude)
 
-	isApproved := true
-	if req.CreatorEmail == "scraper_bot@unispot.ca" {
-		isApproved = false
+	// Content checks decide whether the event goes live, waits for review or is refused
+	screening := safety.Run(safety.Submission{Title: req.Title, Description: req.Description})
+	if screening.Verdict == safety.Reject {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Event was rejected by content checks", "reasons": screening.Reasons})
+		return
 	}
 
+	// A verified creator's reputation decides between publishing, holding and
+	// throttling. Anonymous posts have no reputation to go on, so they rely on
+	// the content checks and per-IP limit alone.
+	tier := reputation.Standard
+	if creatorEmail != "" {
+		tier = reputation.TierFor(creatorEmail, reputation.Score(creatorEmail))
+	}
+	if tier == reputation.Probation && !ratelimit.Default.Allow(c, "create_event_probation", ratelimit.Limit{Requests: 2, Per: time.Hour}) {
+		return
+	}
+
+	status := moderation.Approved
+	var moderationReason string
+	switch {
+	case screening.Verdict == safety.Hold:
+		status = moderation.Pending
+		moderationReason = "Held for review: " + strings.Join(screening.Reasons, "; ")
+	case tier == reputation.Held:
+		status = moderation.Pending
+		moderationReason = "Held for review: creator reputation"
+	case creatorEmail == scraperEmail && tier != reputation.Trusted:
+		// Scraped events wait for an admin until the scraper has earned trust
+		status = moderation.Pending
+	}
+	isApproved := status == moderation.Approved
+
 	event := models.Event{
-		Title:       req.Title,
-		Description: req.Description,
-		Category:    req.Category,
-		Location:    locationStr, // Note: Location is a string in the model, but gorm will use the geography type
-		StartTime:   time.Now().UTC().Add(-1 * time.Minute),
-		EndTime:     time.Now().UTC().Add(time.Duration(req.Duration * float64(time.Hour))),
-		IsApproved:  isApproved,
+		Title:            req.Title,
+		Description:      req.Description,
+		Category:         req.Category,
+		Location:         locationStr, // Note: Location is a string in the model, but gorm will use the geography type
+		StartTime:        startTime,
+		EndTime:          endTime,
+		IsApproved:       isApproved,
+		Capacity:         req.Capacity,
+		SpotsLeft:        req.Capacity,
+		ModerationStatus: status,
+		ModerationReason: moderationReason,
 	}
 
+	organizerToken, organizerTokenHash := newOrganizerToken()
+
 	// Use raw SQL for the insertion to handle the ST_GeogFromText conversion
 	query := `
-		INSERT INTO events (title, description, category, location, start_time, end_time, creator_name, creator_email, is_approved)
-		VALUES (?, ?, ?, ST_GeogFromText(?), ?, ?, ?, ?, ?)
-		RETURNING id
+		INSERT INTO events (title, description, category, location, start_time, end_time, creator_name, creator_email, organizer_token_hash, is_approved, moderation_status, moderation_reason, capacity, created_at)
+		VALUES (?, ?, ?, ST_GeogFromText(?), ?, ?, ?, ?, ?, ?, ?, ?, ?, now())
+		RETURNING id, created_at
 	`
 
-	var id uint
-	if err := database.DB.Raw(query, req.Title, req.Description, req.Category, locationStr, event.StartTime, event.EndTime, req.CreatorName, req.CreatorEmail, isApproved).Scan(&id).Error; err != nil {
+	var inserted struct {
+		ID        uint
+		CreatedAt time.Time
+	}
+	if err := database.DB.Raw(query, req.Title, req.Description, req.Category, locationStr, event.StartTime, event.EndTime, req.CreatorName, creatorEmail, organizerTokenHash, isApproved, status, moderationReason, req.Capacity).Scan(&inserted).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	event.ID = id
+	event.ID = inserted.ID
+	event.CreatedAt = inserted.CreatedAt
 	event.Latitude = req.Latitude
 	event.Longitude = req.Longitude
 
-	// Broadcast the new event to all connected clients
-	ws.GlobalHub.BroadcastEvent("new_event", event)
+	// Broadcast the new event to all connected clients once it is live
+	if isApproved {
+		ws.GlobalHub.BroadcastTopic(ws.EventTopic(&event), "new_event", event)
+		push.Enqueue(event.ID)
+	}
 
-	c.JSON(http.StatusCreated, event)
+	c.JSON(http.StatusCreated, createdEvent{event, organizerToken})
 }
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"github.com/parsaabbasian/unispot/backend/internal/services/digest"
+)
+
+// UnsubscribeDigest turns off a user's digest emails from the signed link in
+// each digest. Mail clients' one-click unsubscribe POSTs to the same URL.
+func UnsubscribeDigest(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Query("u"), 10, 64)
+	if err != nil || !digest.ValidUnsubscribe(uint(userID), c.Query("t")) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This unsubscribe link is invalid"})
+		return
+	}
+
+	if err := database.DB.Model(&models.UserPreference{}).
+		Where("user_id = ?", userID).
+		Update("digest_frequency", models.DigestOff).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Request.Method == http.MethodPost {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(
+		`<!DOCTYPE html><html><body style="font-family:sans-serif;text-align:center;padding:48px">`+
+			`<h2>You're unsubscribed</h2><p>You won't get any more UniSpot digests. You can turn them back on in your preferences.</p>`+
+			`</body></html>`))
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parsaabbasian/unispot/backend/internal/config"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"github.com/parsaabbasian/unispot/backend/internal/services/moderation"
+	"github.com/parsaabbasian/unispot/backend/internal/services/reputation"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errAlreadyDisputed = errors.New("You have already reported this event")
+
+var disputeReasons = map[string]bool{
+	models.DisputeEnded:         true,
+	models.DisputeFake:          true,
+	models.DisputeWrongLocation: true,
+	models.DisputeSpam:          true,
+}
+
+type DisputeEventRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// shouldHide reports whether an event has drawn enough disputes to come off
+// the map: at least DISPUTE_MIN_COUNT of them, and more than
+// DISPUTE_RATIO times its verification count.
+func shouldHide(disputes, verifications int) bool {
+	minCount := config.Int("DISPUTE_MIN_COUNT", 3)
+	ratio := config.Float("DISPUTE_RATIO", 1.0)
+	return disputes >= minCount && float64(disputes) > ratio*float64(verifications)
+}
+
+func DisputeEvent(c *gin.Context) {
+	var req DisputeEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !disputeReasons[req.Reason] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason must be one of ended, fake, wrong_location, spam"})
+		return
+	}
+
+	ipAddress := c.ClientIP()
+	var userEmail string
+	if user := currentUser(c); user != nil {
+		userEmail = user.Email
+	}
+
+	var event models.Event
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(liveEvents).First(&event, c.Param("id")).Error; err != nil {
+			return errEventNotFound
+		}
+
+		// One report per IP, and per signed-in user across IPs
+		dup := tx.Where("event_id = ? AND ip_address = ?", event.ID, ipAddress)
+		if userEmail != "" {
+			dup = tx.Where("event_id = ? AND (ip_address = ? OR user_email = ?)", event.ID, ipAddress, userEmail)
+		}
+		var existing models.Dispute
+		if err := dup.First(&existing).Error; err == nil {
+			return errAlreadyDisputed
+		}
+
+		dispute := models.Dispute{
+			EventID:   event.ID,
+			IPAddress: ipAddress,
+			UserEmail: userEmail,
+			Reason:    req.Reason,
+		}
+		if err := tx.Create(&dispute).Error; err != nil {
+			return errAlreadyDisputed
+		}
+
+		event.DisputeCount++
+		return tx.Model(&event).Update("dispute_count", event.DisputeCount).Error
+	})
+
+	switch {
+	case errors.Is(err, errEventNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errAlreadyDisputed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	reputation.Record(event.CreatorEmail, reputation.DisputeReceived)
+
+	// Off the map and into the moderation queue until an admin reviews it
+	if event.ModerationStatus == moderation.Approved && shouldHide(event.DisputeCount, event.VerifiedCount) {
+		reason := fmt.Sprintf("Automatically hidden after %d reports", event.DisputeCount)
+		if hiddenEvent, err := moderation.Transition(event.ID, moderation.Hidden, reason, "system"); err == nil {
+			event = *hiddenEvent
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Report received",
+		"dispute_count": event.DisputeCount,
+		"is_hidden":     event.IsHidden,
+	})
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/parsaabbasian/unispot/backend/internal/config"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+)
+
+type duplicateCandidate struct {
+	ID            uint      `json:"id"`
+	Title         string    `json:"title"`
+	Category      string    `json:"category"`
+	Latitude      float64   `gorm:"column:lat" json:"lat"`
+	Longitude     float64   `gorm:"column:lng" json:"lng"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	VerifiedCount int       `json:"verified_count"`
+	DistanceM     float64   `gorm:"column:distance_m" json:"distance_m"`
+	Similarity    float64   `json:"similarity"`
+}
+
+// findDuplicates looks for events on the public map in the same category
+// within DUPLICATE_RADIUS_M metres whose time window overlaps the new one. A
+// candidate must also have a similar title (pg_trgm similarity of at least
+// DUPLICATE_MIN_SIMILARITY) or sit within DUPLICATE_CLOSE_RADIUS_M metres.
+// Pending and hidden events are left out since the 409 shows them to anyone.
+func findDuplicates(req CreateEventRequest, start, end time.Time) ([]duplicateCandidate, error) {
+	radius := config.Float("DUPLICATE_RADIUS_M", 100)
+	closeRadius := config.Float("DUPLICATE_CLOSE_RADIUS_M", 25)
+	minSimilarity := config.Float("DUPLICATE_MIN_SIMILARITY", 0.3)
+
+	query := `
+		WITH candidates AS (
+			SELECT
+				e.id, e.title, e.category, e.start_time, e.end_time, e.verified_count,
+				ST_Y(e.location::geometry) as lat, ST_X(e.location::geometry) as lng,
+				ST_Distance(e.location, ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography) as distance_m,
+				similarity(e.title, ?) as similarity
+			FROM events e
+			WHERE e.category = ?
+			  AND e.moderation_status = 'approved'
+			  AND e.is_hidden = false
+			  AND e.archived_at IS NULL
+			  AND e.end_time >= now()
+			  AND e.end_time >= ? AND e.start_time <= ?
+			  AND ST_DWithin(e.location, ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography, ?)
+		)
+		SELECT * FROM candidates
+		WHERE similarity >= ? OR distance_m <= ?
+		ORDER BY similarity DESC, distance_m ASC
+		LIMIT 5
+	`
+
+	var candidates []duplicateCandidate
+	err := database.DB.Raw(query,
+		req.Longitude, req.Latitude, req.Title, req.Category,
+		start, end,
+		req.Longitude, req.Latitude, radius,
+		minSimilarity, closeRadius,
+	).Scan(&candidates).Error
+	return candidates, err
+}
@@ -4,11 +4,14 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lib/pq"
 	"github.com/parsaabbasian/unispot/backend/internal/database"
 	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"github.com/parsaabbasian/unispot/backend/internal/services/confidence"
+	"gorm.io/gorm"
 )
 
 func GetEvents(c *gin.Context) {
@@ -25,6 +28,10 @@ func GetEvents(c *gin.Context) {
 		models.Event
 		Loc       string         `gorm:"column:location_text"`
 		Verifiers pq.StringArray `gorm:"column:verifier_names"`
+		SpotsLeft *int           `gorm:"column:spots_left"`
+		Decayed   float64        `gorm:"column:decayed_verifications"`
+		LastVerif *time.Time     `gorm:"column:last_verified_at"`
+		Rep       float64        `gorm:"column:creator_reputation"`
 	}
 
 	// Geospatial query using ST_DWithin and time filtering
@@ -32,37 +39,112 @@ func GetEvents(c *gin.Context) {
 	query := `
 		SELECT 
 			e.id, e.title, e.description, e.category, ST_AsText(e.location) as location_text, 
-			e.start_time, e.end_time, e.verified_count, e.creator_name, e.is_approved,
-			COALESCE(array_agg(v.user_name) FILTER (WHERE v.user_name IS NOT NULL), '{}') as verifier_names
+			e.start_time, e.end_time, e.verified_count, e.creator_name, e.is_approved, e.capacity,
+			e.dispute_count,
+			COALESCE(array_agg(v.user_name) FILTER (WHERE v.user_name IS NOT NULL), '{}') as verifier_names,
+			COALESCE(SUM(power(0.5, EXTRACT(EPOCH FROM now() - v.created_at) / ?)), 0) as decayed_verifications,
+			MAX(v.created_at) as last_verified_at,
+			COALESCE((SELECT cr.score FROM creator_reputations cr WHERE cr.email = LOWER(e.creator_email)), 0) as creator_reputation,
+			CASE WHEN e.capacity IS NOT NULL THEN GREATEST(e.capacity - (
+				SELECT COUNT(*) FROM rsvps r WHERE r.event_id = e.id AND r.status = 'going'
+			), 0) END as spots_left
 		FROM events e
 		LEFT JOIN verifications v ON e.id = v.event_id
 		WHERE ST_DWithin(e.location, ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography, ?)
 		  AND e.start_time <= now()
 		  AND e.end_time >= now()
 		  AND e.is_approved = true
+		  AND e.is_hidden = false
+		  AND e.archived_at IS NULL
 		GROUP BY e.id, location_text
 	`
 
-	if err := database.DB.Raw(query, lng, lat, radius).Scan(&rawEvents).Error; err != nil {
+	if err := database.DB.Raw(query, confidence.HalfLife().Seconds(), lng, lat, radius).Scan(&rawEvents).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	ids := make([]uint, len(rawEvents))
+	for i, re := range rawEvents {
+		ids[i] = re.ID
+	}
+	reactions, err := reactionCounts(ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
 	events := make([]models.Event, len(rawEvents))
 	for i, re := range rawEvents {
 		events[i] = re.Event
 		events[i].Location = re.Loc
 		events[i].Verifiers = []string(re.Verifiers)
+		events[i].SpotsLeft = re.SpotsLeft
+		events[i].LastVerifiedAt = re.LastVerif
+		events[i].Confidence = confidence.Score(re.Decayed, re.StartTime, re.DisputeCount, re.Rep, now)
+		events[i].Reactions = reactions[re.ID]
+		setLatLng(&events[i], re.Loc)
+	}
 
-		// Parse POINT(lng lat)
-		loc := strings.TrimPrefix(re.Loc, "POINT(")
-		loc = strings.TrimSuffix(loc, ")")
-		parts := strings.Split(loc, " ")
-		if len(parts) == 2 {
-			fmt.Sscanf(parts[0], "%f", &events[i].Longitude)
-			fmt.Sscanf(parts[1], "%f", &events[i].Latitude)
+	// Signed-in users can ask for their mutes and follows to be applied
+	if c.Query("personalized") == "true" {
+		if user := currentUser(c); user != nil {
+			prefs, err := loadPreferences(user)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			events = personalize(events, prefs)
 		}
 	}
 
 	c.JSON(http.StatusOK, events)
 }
+
+// setLatLng fills in Latitude/Longitude from ST_AsText output, i.e. POINT(lng lat).
+func setLatLng(e *models.Event, pointText string) {
+	e.Latitude, e.Longitude = parsePoint(pointText)
+}
+
+// parsePoint reads latitude and longitude out of ST_AsText output.
+func parsePoint(pointText string) (lat, lng float64) {
+	loc := strings.TrimPrefix(pointText, "POINT(")
+	loc = strings.TrimSuffix(loc, ")")
+	parts := strings.Split(loc, " ")
+	if len(parts) == 2 {
+		fmt.Sscanf(parts[0], "%f", &lng)
+		fmt.Sscanf(parts[1], "%f", &lat)
+	}
+	return lat, lng
+}
+
+// liveEvents scopes a query to events still on the live map.
+func liveEvents(db *gorm.DB) *gorm.DB {
+	return db.Where("archived_at IS NULL")
+}
+
+// publicEvents scopes a query to live events that are approved and not hidden.
+func publicEvents(db *gorm.DB) *gorm.DB {
+	return liveEvents(db).Where("is_approved = ? AND is_hidden = ?", true, false)
+}
+
+// loadEvent fetches a single event with its coordinates filled in.
+func loadEvent(id uint) (*models.Event, error) {
+	var res struct {
+		models.Event
+		Loc string `gorm:"column:location_text"`
+	}
+	query := `SELECT e.*, ST_AsText(e.location) as location_text FROM events e WHERE e.id = ?`
+	if err := database.DB.Raw(query, id).Scan(&res).Error; err != nil {
+		return nil, err
+	}
+	if res.ID == 0 {
+		return nil, errEventNotFound
+	}
+
+	event := res.Event
+	event.Location = res.Loc
+	setLatLng(&event, res.Loc)
+	return &event, nil
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parsaabbasian/unispot/backend/internal/config"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// categoryMaxDuration caps how long after its start an event in each category
+// may run. Override with EVENT_MAX_DURATION_<CATEGORY>, e.g. EVENT_MAX_DURATION_SPORTS=6h.
+var categoryMaxDuration = map[string]time.Duration{
+	"Food":   4 * time.Hour,
+	"Study":  8 * time.Hour,
+	"Social": 6 * time.Hour,
+	"Tech":   12 * time.Hour,
+	"Music":  6 * time.Hour,
+	"Sports": 5 * time.Hour,
+	"Safety": 3 * time.Hour,
+	"Sale":   10 * time.Hour,
+}
+
+const defaultMaxDuration = 8 * time.Hour
+
+// An event can't be shortened to end sooner than this; delete it instead.
+const minRemaining = 5 * time.Minute
+
+var errEventEnded = errors.New("This event has already ended")
+
+// endTimeError explains why a requested end time isn't allowed.
+type endTimeError struct{ msg string }
+
+func (e *endTimeError) Error() string { return e.msg }
+
+type ExtendEventRequest struct {
+	Minutes int `json:"minutes" binding:"required"` // negative to shorten
+}
+
+func maxDuration(category string) time.Duration {
+	def, ok := categoryMaxDuration[category]
+	if !ok {
+		def = defaultMaxDuration
+	}
+	return config.Duration("EVENT_MAX_DURATION_"+strings.ToUpper(category), def)
+}
+
+func maxEndTime(event *models.Event) time.Time {
+	return event.StartTime.Add(maxDuration(event.Category))
+}
+
+// updateEndTime locks a live event and moves its end_time to whatever next
+// returns. It reports whether anything changed. The update trigger tells
+// clients about the new end time, so callers don't broadcast it.
+func updateEndTime(id uint, next func(event *models.Event) (time.Time, error)) (*models.Event, bool, error) {
+	var changed bool
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var event models.Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(liveEvents).First(&event, id).Error; err != nil {
+			return errEventNotFound
+		}
+		if event.EndTime.Before(time.Now()) {
+			return errEventEnded
+		}
+
+		endTime, err := next(&event)
+		if err != nil {
+			return err
+		}
+		if endTime.Equal(event.EndTime) {
+			return nil
+		}
+		changed = true
+		return tx.Model(&event).Update("end_time", endTime.UTC()).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	event, err := loadEvent(id)
+	return event, changed, err
+}
+
+// shiftEndTime applies a creator or admin change of minutes to the end time,
+// keeping it within the category limit. Shortening is always allowed, even
+// for events that were already past the limit.
+func shiftEndTime(c *gin.Context, id uint) {
+	var req ExtendEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, _, err := updateEndTime(id, func(event *models.Event) (time.Time, error) {
+		endTime := event.EndTime.Add(time.Duration(req.Minutes) * time.Minute)
+		if limit := maxEndTime(event); endTime.After(event.EndTime) && endTime.After(limit) {
+			return time.Time{}, &endTimeError{fmt.Sprintf("%s events can run until %s at the latest", event.Category, limit.In(config.Toronto()).Format(time.Kitchen))}
+		}
+		if endTime.Before(time.Now().Add(minRemaining)) {
+			return time.Time{}, &endTimeError{fmt.Sprintf("An event can't be cut to under %d minutes from now; delete it instead", int(minRemaining.Minutes()))}
+		}
+		return endTime, nil
+	})
+
+	var rangeErr *endTimeError
+	switch {
+	case errors.Is(err, errEventNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errEventEnded):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.As(err, &rangeErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
+}
+
+// ExtendEvent lets an event's creator move its end time.
+func ExtendEvent(c *gin.Context) {
+	event, ok := loadOrganizerEvent(c)
+	if !ok {
+		return
+	}
+	shiftEndTime(c, event.ID)
+}
+
+func AdminExtendEvent(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	shiftEndTime(c, uint(id))
+}
+
+// StillHappening lets someone who verified an event confirm it's still on.
+// It keeps the event up for at least STILL_HAPPENING_NUDGE from now, never
+// past the category limit.
+func StillHappening(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	verified := database.DB.Where("event_id = ? AND ip_address = ?", id, c.ClientIP())
+	if user := currentUser(c); user != nil {
+		verified = database.DB.Where("event_id = ? AND (ip_address = ? OR LOWER(user_email) = ?)", id, c.ClientIP(), user.Email)
+	}
+	var verification models.Verification
+	if err := verified.First(&verification).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Verify this event first"})
+		return
+	}
+
+	nudge := config.Duration("STILL_HAPPENING_NUDGE", 15*time.Minute)
+	event, changed, err := updateEndTime(uint(id), func(event *models.Event) (time.Time, error) {
+		endTime := time.Now().Add(nudge)
+		if limit := maxEndTime(event); endTime.After(limit) {
+			endTime = limit
+		}
+		if !endTime.After(event.EndTime) {
+			return event.EndTime, nil
+		}
+		return endTime, nil
+	})
+
+	switch {
+	case errors.Is(err, errEventNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errEventEnded):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Thanks for confirming",
+		"extended": changed,
+		"end_time": event.EndTime,
+	})
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+)
+
+// Grid cells are built in UTM zone 17N so cell sizes are true metres around campus.
+const (
+	heatmapSRID        = 32617
+	heatmapDefaultCell = 75.0
+	heatmapMinCell     = 20.0
+	heatmapMaxCell     = 500.0
+)
+
+type heatmapFeature struct {
+	Type       string          `json:"type"`
+	Geometry   json.RawMessage `json:"geometry"`
+	Properties gin.H           `json:"properties"`
+}
+
+// GetHeatmap aggregates published events (live and archived) that overlap
+// from/to onto a hex or square grid over campus, returned as GeoJSON.
+// Optional params: category, shape=hex|square, cell_m (cell size in metres).
+func GetHeatmap(c *gin.Context) {
+	from, to, ok := parseRange(c)
+	if !ok {
+		return
+	}
+	if to.Sub(from) > historyMaxRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Heatmap range can be at most a year"})
+		return
+	}
+
+	gridFunc := "ST_HexagonGrid"
+	switch c.DefaultQuery("shape", "hex") {
+	case "hex":
+	case "square":
+		gridFunc = "ST_SquareGrid"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "shape must be hex or square"})
+		return
+	}
+
+	cell := heatmapDefaultCell
+	if v := c.Query("cell_m"); v != "" {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil || parsed < heatmapMinCell || parsed > heatmapMaxCell {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("cell_m must be between %.0f and %.0f", heatmapMinCell, heatmapMaxCell)})
+			return
+		}
+		cell = parsed
+	}
+	category := c.Query("category")
+
+	query := fmt.Sprintf(`
+		WITH bounds AS (
+			SELECT ST_Expand(ST_Transform(ST_SetSRID(ST_MakePoint(?, ?), 4326), %[1]d), ?) AS geom
+		),
+		cells AS (
+			SELECT g.i, g.j, g.geom FROM bounds, %[2]s(?, bounds.geom) g
+		),
+		points AS (
+			SELECT e.category, ST_Transform(e.location::geometry, %[1]d) AS geom
+			FROM events e
+			WHERE e.moderation_status = 'approved'
+			  AND e.start_time < ? AND e.end_time > ?
+			  AND (? = '' OR e.category = ?)
+		),
+		per_category AS (
+			SELECT c.i, c.j, p.category, COUNT(*) AS n
+			FROM cells c
+			JOIN points p ON ST_Intersects(c.geom, p.geom)
+			GROUP BY c.i, c.j, p.category
+		),
+		per_cell AS (
+			SELECT i, j,
+				SUM(n)::int AS count,
+				(array_agg(category ORDER BY n DESC, category))[1] AS dominant_category,
+				jsonb_object_agg(category, n) AS categories
+			FROM per_category
+			GROUP BY i, j
+		)
+		SELECT ST_AsGeoJSON(ST_Transform(c.geom, 4326)) AS geometry, pc.count, pc.dominant_category, pc.categories
+		FROM per_cell pc
+		JOIN cells c ON c.i = pc.i AND c.j = pc.j
+		ORDER BY pc.count DESC
+	`, heatmapSRID, gridFunc)
+
+	var cells []struct {
+		Geometry         string
+		Count            int
+		DominantCategory string
+		Categories       string
+	}
+	if err := database.DB.Raw(query,
+		YorkULng, YorkULat, MaxDistanceKm*1000,
+		cell,
+		to, from,
+		category, category,
+	).Scan(&cells).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	features := make([]heatmapFeature, len(cells))
+	for i, cl := range cells {
+		features[i] = heatmapFeature{
+			Type:     "Feature",
+			Geometry: json.RawMessage(cl.Geometry),
+			Properties: gin.H{
+				"count":             cl.Count,
+				"dominant_category": cl.DominantCategory,
+				"categories":        json.RawMessage(cl.Categories),
+			},
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"type":     "FeatureCollection",
+		"features": features,
+	})
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"github.com/parsaabbasian/unispot/backend/internal/ws"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MergeEventsRequest struct {
+	SourceIDs []uint `json:"source_ids" binding:"required,min=1"`
+}
+
+// mergedTables lists the per-event tables moved onto the merge target, with
+// the column that must stay unique per event.
+var mergedTables = []struct{ table, key string }{
+	{"verifications", "ip_address"},
+	{"rsvps", "user_id"},
+	{"attendances", "user_id"},
+	{"disputes", "ip_address"},
+	{"comments", "id"}, // every comment moves
+	{"reactions", "ip_address"},
+	{"event_views", "user_id"},
+	{"push_notifications", "subscription_id"},
+	{"webhook_messages", "subscription_id"},
+}
+
+// moveRows re-points source's rows in table to target, dropping any whose key
+// the target already has so the per-event unique indexes hold.
+func moveRows(tx *gorm.DB, table, key string, target, source uint) error {
+	if err := tx.Exec(fmt.Sprintf(
+		`UPDATE %[1]s SET event_id = ? WHERE event_id = ? AND %[2]s NOT IN (SELECT %[2]s FROM %[1]s WHERE event_id = ?)`,
+		table, key,
+	), target, source, target).Error; err != nil {
+		return err
+	}
+	return tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE event_id = ?`, table), source).Error
+}
+
+// AdminMergeEvents folds duplicate events into the one in the :id param and deletes them.
+func AdminMergeEvents(c *gin.Context) {
+	targetID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	var req MergeEventsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, id := range req.SourceIDs {
+		if id == uint(targetID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "An event can't be merged into itself"})
+			return
+		}
+	}
+
+	var target models.Event
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&target, targetID).Error; err != nil {
+			return errEventNotFound
+		}
+		var sources []models.Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Find(&sources, req.SourceIDs).Error; err != nil {
+			return err
+		}
+		if len(sources) != len(req.SourceIDs) {
+			return errEventNotFound
+		}
+
+		for _, source := range sources {
+			for _, t := range mergedTables {
+				if err := moveRows(tx, t.table, t.key, target.ID, source.ID); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Merged RSVPs compete for the target's spots first-come, first-served
+		if target.Capacity != nil {
+			if err := tx.Exec(`
+				UPDATE rsvps r SET status = CASE WHEN ranked.rn <= ? THEN 'going' ELSE 'waitlisted' END
+				FROM (
+					SELECT id, row_number() OVER (ORDER BY created_at, id) as rn
+					FROM rsvps WHERE event_id = ?
+				) ranked
+				WHERE r.id = ranked.id
+			`, *target.Capacity, target.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec(`
+			UPDATE events SET
+				verified_count = (SELECT COUNT(*) FROM verifications WHERE event_id = ?),
+				dispute_count = (SELECT COUNT(*) FROM disputes WHERE event_id = ?)
+			WHERE id = ?
+		`, target.ID, target.ID, target.ID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&models.Event{}, req.SourceIDs).Error; err != nil {
+			return err
+		}
+		return tx.First(&target, target.ID).Error
+	})
+
+	switch {
+	case errors.Is(err, errEventNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Lets clients re-point open detail overlays from a source to the target
+	ws.GlobalHub.BroadcastEvent("merge_event", gin.H{
+		"id":             target.ID,
+		"merged_ids":     req.SourceIDs,
+		"verified_count": target.VerifiedCount,
+	})
+
+	c.JSON(http.StatusOK, target)
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parsaabbasian/unispot/backend/internal/auth"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"github.com/parsaabbasian/unispot/backend/internal/services/moderation"
+)
+
+type ModerateEventRequest struct {
+	Status string `json:"status" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// adminActor names the admin for the moderation log.
+func adminActor(c *gin.Context) string {
+	if identity := auth.FromRequest(c); identity != nil {
+		return identity.Email
+	}
+	return "admin"
+}
+
+// AdminModerationQueue lists events awaiting review (pending or hidden),
+// most reported first and then oldest first.
+func AdminModerationQueue(c *gin.Context) {
+	var results []struct {
+		models.Event
+		LocationText string `gorm:"column:location_text"`
+	}
+
+	query := `
+		SELECT e.*, ST_AsText(e.location) as location_text
+		FROM events e
+		WHERE e.moderation_status IN ('pending', 'hidden')
+		  AND e.archived_at IS NULL
+		ORDER BY e.dispute_count DESC, COALESCE(e.created_at, e.start_time) ASC
+	`
+	if err := database.DB.Raw(query).Scan(&results).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var reasonCounts []struct {
+		EventID uint
+		Reason  string
+		Count   int
+	}
+	if err := database.DB.Raw(`
+		SELECT d.event_id, d.reason, COUNT(*) as count
+		FROM disputes d
+		JOIN events e ON e.id = d.event_id
+		WHERE e.moderation_status IN ('pending', 'hidden')
+		  AND e.archived_at IS NULL
+		GROUP BY d.event_id, d.reason
+	`).Scan(&reasonCounts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	reasons := make(map[uint]map[string]int)
+	for _, rc := range reasonCounts {
+		if reasons[rc.EventID] == nil {
+			reasons[rc.EventID] = make(map[string]int)
+		}
+		reasons[rc.EventID][rc.Reason] = rc.Count
+	}
+
+	type queueItem struct {
+		adminEvent
+		DisputeReasons map[string]int `json:"dispute_reasons"`
+	}
+	queue := make([]queueItem, len(results))
+	for i, res := range results {
+		queue[i] = queueItem{adminEvent: adminEvent{res.Event, res.CreatorEmail}, DisputeReasons: reasons[res.ID]}
+		setLatLng(&queue[i].Event, res.LocationText)
+	}
+
+	c.JSON(http.StatusOK, queue)
+}
+
+func AdminModerateEvent(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	var req ModerateEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err := moderation.Transition(uint(id), req.Status, req.Reason, adminActor(c))
+	switch {
+	case errors.Is(err, moderation.ErrEventNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, moderation.ErrInvalidStatus), errors.Is(err, moderation.ErrReasonRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
+}
+
+// GetEventStatus shows an event's creator where it stands in moderation and why.
+func GetEventStatus(c *gin.Context) {
+	event, ok := loadOrganizerEvent(c)
+	if !ok {
+		return
+	}
+
+	var history []models.ModerationLog
+	if err := database.DB.Where("event_id = ?", event.ID).Order("created_at ASC").Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Admin identities stay internal
+	entries := make([]gin.H, len(history))
+	for i, h := range history {
+		entries[i] = gin.H{
+			"status":     h.ToStatus,
+			"reason":     h.Reason,
+			"changed_at": h.CreatedAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":      event.ID,
+		"status":  event.ModerationStatus,
+		"reason":  event.ModerationReason,
+		"history": entries,
+	})
+}
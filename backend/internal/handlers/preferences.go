@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parsaabbasian/unispot/backend/internal/campus"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"gorm.io/gorm/clause"
+)
+
+// Most entries allowed in any one preference list.
+const maxPreferenceEntries = 50
+
+// PreferencesRequest updates a user's preferences. PUT replaces every list,
+// treating missing ones as empty; PATCH only touches the lists it is given.
+type PreferencesRequest struct {
+	FollowedCategories *[]string `json:"followed_categories"`
+	FollowedCreators   *[]string `json:"followed_creators"`
+	FollowedBuildings  *[]string `json:"followed_buildings"`
+	MutedCategories    *[]string `json:"muted_categories"`
+	HomeCampus         *string   `json:"home_campus"`
+	DigestFrequency    *string   `json:"digest_frequency"` // off, daily or weekly
+}
+
+var digestFrequencies = map[string]bool{
+	models.DigestOff:    true,
+	models.DigestDaily:  true,
+	models.DigestWeekly: true,
+}
+
+// cleanList trims values and drops blanks and case-insensitive repeats.
+func cleanList(field string, values []string) ([]string, error) {
+	seen := make(map[string]bool)
+	cleaned := []string{}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" || seen[strings.ToLower(v)] {
+			continue
+		}
+		seen[strings.ToLower(v)] = true
+		cleaned = append(cleaned, v)
+	}
+	if len(cleaned) > maxPreferenceEntries {
+		return nil, fmt.Errorf("%s can have at most %d entries", field, maxPreferenceEntries)
+	}
+	return cleaned, nil
+}
+
+// cleanBuildings resolves building names or slugs to slugs.
+func cleanBuildings(values []string) ([]string, error) {
+	cleaned, err := cleanList("followed_buildings", values)
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range cleaned {
+		b, ok := campus.Find(v)
+		if !ok {
+			return nil, fmt.Errorf("Unknown building %q", v)
+		}
+		cleaned[i] = b.Slug
+	}
+	return cleanList("followed_buildings", cleaned)
+}
+
+// loadPreferences returns the user's saved preferences, or empty ones.
+func loadPreferences(user *models.User) (*models.UserPreference, error) {
+	prefs := models.UserPreference{UserID: user.ID}
+	if err := database.DB.Where("user_id = ?", user.ID).Limit(1).Find(&prefs).Error; err != nil {
+		return nil, err
+	}
+	for _, list := range []*[]string{
+		(*[]string)(&prefs.FollowedCategories),
+		(*[]string)(&prefs.FollowedCreators),
+		(*[]string)(&prefs.FollowedBuildings),
+		(*[]string)(&prefs.MutedCategories),
+	} {
+		if *list == nil {
+			*list = []string{}
+		}
+	}
+	if prefs.DigestFrequency == "" {
+		prefs.DigestFrequency = models.DigestOff
+	}
+	return &prefs, nil
+}
+
+func GetPreferences(c *gin.Context) {
+	user, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	prefs, err := loadPreferences(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// UpdatePreferences handles both PUT and PATCH.
+func UpdatePreferences(c *gin.Context) {
+	user, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	var req PreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	prefs, err := loadPreferences(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	replace := c.Request.Method == http.MethodPut
+	lists := []struct {
+		field string
+		value *[]string
+		dest  *[]string
+	}{
+		{"followed_categories", req.FollowedCategories, (*[]string)(&prefs.FollowedCategories)},
+		{"followed_creators", req.FollowedCreators, (*[]string)(&prefs.FollowedCreators)},
+		{"followed_buildings", req.FollowedBuildings, (*[]string)(&prefs.FollowedBuildings)},
+		{"muted_categories", req.MutedCategories, (*[]string)(&prefs.MutedCategories)},
+	}
+	for _, l := range lists {
+		if l.value == nil {
+			if replace {
+				*l.dest = []string{}
+			}
+			continue
+		}
+
+		var cleaned []string
+		if l.field == "followed_buildings" {
+			cleaned, err = cleanBuildings(*l.value)
+		} else {
+			cleaned, err = cleanList(l.field, *l.value)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		*l.dest = cleaned
+	}
+
+	switch {
+	case req.HomeCampus != nil && *req.HomeCampus != "":
+		home, ok := campus.FindCampus(*req.HomeCampus)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown campus %q", *req.HomeCampus)})
+			return
+		}
+		prefs.HomeCampus = home.Slug
+	case req.HomeCampus != nil || replace:
+		prefs.HomeCampus = ""
+	}
+
+	switch {
+	case req.DigestFrequency != nil:
+		if !digestFrequencies[*req.DigestFrequency] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "digest_frequency must be one of off, daily, weekly"})
+			return
+		}
+		prefs.DigestFrequency = *req.DigestFrequency
+	case replace:
+		prefs.DigestFrequency = models.DigestOff
+	}
+
+	if err := database.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(prefs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+func DeletePreferences(c *gin.Context) {
+	user, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Delete(&models.UserPreference{}, user.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Preferences cleared"})
+}
+
+// GetBuildings lists the campus buildings that can be followed.
+func GetBuildings(c *gin.Context) {
+	c.JSON(http.StatusOK, campus.Buildings)
+}
+
+// GetCampuses lists the campuses that can be set as home.
+func GetCampuses(c *gin.Context) {
+	c.JSON(http.StatusOK, campus.Campuses)
+}
+
+func containsFold(list []string, v string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// personalize drops events in muted categories and moves events matching a
+// follow to the front, flagging them as followed.
+func personalize(events []models.Event, prefs *models.UserPreference) []models.Event {
+	var buildings []campus.Building
+	for _, slug := range prefs.FollowedBuildings {
+		if b, ok := campus.Find(slug); ok {
+			buildings = append(buildings, b)
+		}
+	}
+
+	kept := events[:0]
+	for _, e := range events {
+		if containsFold(prefs.MutedCategories, e.Category) {
+			continue
+		}
+		e.Followed = containsFold(prefs.FollowedCategories, e.Category) ||
+			(e.CreatorName != "" && containsFold(prefs.FollowedCreators, e.CreatorName))
+		for _, b := range buildings {
+			if e.Followed {
+				break
+			}
+			e.Followed = b.Contains(e.Latitude, e.Longitude)
+		}
+		kept = append(kept, e)
+	}
+
+	sort.SliceStable(kept, func(a, b int) bool { return kept[a].Followed && !kept[b].Followed })
+	return kept
+}
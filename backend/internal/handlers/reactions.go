@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"github.com/parsaabbasian/unispot/backend/internal/ws"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var reactionEmoji = map[string]bool{
+	models.ReactionFire:  true,
+	models.ReactionLike:  true,
+	models.ReactionYum:   true,
+	models.ReactionCross: true,
+}
+
+type ReactRequest struct {
+	Emoji string `json:"emoji" binding:"required"`
+}
+
+// reactionCounts totals reactions per emoji for each of eventIDs.
+func reactionCounts(eventIDs []uint) (map[uint]map[string]int, error) {
+	counts := make(map[uint]map[string]int)
+	if len(eventIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		EventID uint
+		Emoji   string
+		Count   int
+	}
+	if err := database.DB.Raw(`
+		SELECT event_id, emoji, COUNT(*) as count
+		FROM reactions
+		WHERE event_id IN ?
+		GROUP BY event_id, emoji
+	`, eventIDs).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, r := range rows {
+		if counts[r.EventID] == nil {
+			counts[r.EventID] = make(map[string]int)
+		}
+		counts[r.EventID][r.Emoji] = r.Count
+	}
+	return counts, nil
+}
+
+// reactionFilter matches the caller's reaction on an event: by IP, and by
+// email across IPs when signed in.
+func reactionFilter(c *gin.Context, eventID uint) (*gorm.DB, string) {
+	ipAddress := c.ClientIP()
+	if user := currentUser(c); user != nil {
+		return database.DB.Where("event_id = ? AND (ip_address = ? OR user_email = ?)", eventID, ipAddress, user.Email), user.Email
+	}
+	return database.DB.Where("event_id = ? AND ip_address = ?", eventID, ipAddress), ""
+}
+
+// broadcastReactions sends an event's current totals. Taps on a busy event are
+// coalesced by the hub so clients get at most one update per window.
+func broadcastReactions(eventID uint) (map[string]int, error) {
+	counts, err := reactionCounts([]uint{eventID})
+	if err != nil {
+		return nil, err
+	}
+	current := counts[eventID]
+	if current == nil {
+		current = map[string]int{}
+	}
+
+	ws.GlobalHub.BroadcastCoalesced(fmt.Sprintf("reaction:%d", eventID), "reaction", gin.H{"id": eventID, "counts": current})
+	return current, nil
+}
+
+func ReactToEvent(c *gin.Context) {
+	var req ReactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !reactionEmoji[req.Emoji] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "emoji must be one of 🔥 👍 😋 ❌"})
+		return
+	}
+
+	event, ok := loadPublicEvent(c)
+	if !ok {
+		return
+	}
+
+	filter, userEmail := reactionFilter(c, event.ID)
+	var existing models.Reaction
+	var err error
+	if filter.First(&existing).Error == nil {
+		err = database.DB.Model(&existing).Update("emoji", req.Emoji).Error
+	} else {
+		err = database.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "event_id"}, {Name: "ip_address"}},
+			DoUpdates: clause.AssignmentColumns([]string{"emoji", "user_email"}),
+		}).Create(&models.Reaction{
+			EventID:   event.ID,
+			IPAddress: c.ClientIP(),
+			UserEmail: userEmail,
+			Emoji:     req.Emoji,
+		}).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	counts, err := broadcastReactions(event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"emoji": req.Emoji, "reactions": counts})
+}
+
+func RemoveReaction(c *gin.Context) {
+	event, ok := loadPublicEvent(c)
+	if !ok {
+		return
+	}
+
+	filter, _ := reactionFilter(c, event.ID)
+	if err := filter.Delete(&models.Reaction{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	counts, err := broadcastReactions(event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reactions": counts})
+}
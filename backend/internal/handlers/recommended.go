@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"github.com/parsaabbasian/unispot/backend/internal/services/recommendation"
+	"gorm.io/gorm/clause"
+)
+
+const recommendedLimit = 20
+
+type recommendedEvent struct {
+	models.Event
+	Score       float64 `json:"score"`
+	Recommended bool    `json:"recommended"` // boosted by the user's category history
+}
+
+// GetRecommendedEvents ranks live events around lat/lng for the signed-in
+// user. Anonymous users get the same ranking without category boosts.
+func GetRecommendedEvents(c *gin.Context) {
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lng, errLng := strconv.ParseFloat(c.Query("lng"), 64)
+	if errLat != nil || errLng != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat and lng are required"})
+		return
+	}
+
+	weights := recommendation.LoadWeights()
+	prefs, err := recommendation.Preferences(currentUser(c), weights)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var rawEvents []struct {
+		models.Event
+		Loc       string  `gorm:"column:location_text"`
+		DistanceM float64 `gorm:"column:distance_m"`
+	}
+	query := `
+		SELECT
+			e.id, e.title, e.description, e.category, ST_AsText(e.location) as location_text,
+			e.start_time, e.end_time, e.verified_count, e.creator_name, e.is_approved, e.capacity,
+			ST_Distance(e.location, ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography) as distance_m
+		FROM events e
+		WHERE ST_DWithin(e.location, ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography, ?)
+		  AND e.start_time <= now() AND e.end_time >= now()
+		  AND e.is_approved = true AND e.is_hidden = false AND e.archived_at IS NULL
+	`
+	if err := database.DB.Raw(query, lng, lat, lng, lat, MaxDistanceKm*1000).Scan(&rawEvents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	events := make([]recommendedEvent, len(rawEvents))
+	for i, re := range rawEvents {
+		score, match := recommendation.Score(prefs, re.Category, re.DistanceM/1000, weights)
+		events[i] = recommendedEvent{Event: re.Event, Score: score, Recommended: match > 0}
+		events[i].Location = re.Loc
+		setLatLng(&events[i].Event, re.Loc)
+	}
+	sort.SliceStable(events, func(a, b int) bool { return events[a].Score > events[b].Score })
+	if len(events) > recommendedLimit {
+		events = events[:recommendedLimit]
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// RecordEventView notes that the signed-in user opened an event on the
+// public map. Anonymous views aren't tracked.
+func RecordEventView(c *gin.Context) {
+	event, ok := loadPublicEvent(c)
+	if !ok {
+		return
+	}
+	user := currentUser(c)
+	if user == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	view := models.EventView{EventID: event.ID, UserID: user.ID}
+	if err := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&view).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
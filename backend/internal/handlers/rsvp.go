@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"github.com/parsaabbasian/unispot/backend/internal/ws"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errEventNotFound = errors.New("Event not found")
+	errAlreadyRSVPd  = errors.New("You have already RSVP'd to this event")
+	errNoRSVP        = errors.New("You have not RSVP'd to this event")
+)
+
+// spotsLeft returns how many "going" places remain, or nil for events without a capacity.
+func spotsLeft(tx *gorm.DB, event models.Event) *int {
+	if event.Capacity == nil {
+		return nil
+	}
+	var going int64
+	tx.Model(&models.RSVP{}).Where("event_id = ? AND status = ?", event.ID, models.RSVPGoing).Count(&going)
+	left := *event.Capacity - int(going)
+	if left < 0 {
+		left = 0
+	}
+	return &left
+}
+
+// waitlistPosition returns the 1-based position of rsvp on its event's waitlist.
+func waitlistPosition(tx *gorm.DB, rsvp models.RSVP) int {
+	var ahead int64
+	tx.Model(&models.RSVP{}).
+		Where("event_id = ? AND status = ? AND (created_at < ? OR (created_at = ? AND id < ?))",
+			rsvp.EventID, models.RSVPWaitlisted, rsvp.CreatedAt, rsvp.CreatedAt, rsvp.ID).
+		Count(&ahead)
+	return int(ahead) + 1
+}
+
+func RSVPEvent(c *gin.Context) {
+	user, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	id := c.Param("id")
+
+	var rsvp models.RSVP
+	var left *int
+	position := 0
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the event row so concurrent RSVPs can't both take the last spot
+		var event models.Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(publicEvents).First(&event, id).Error; err != nil {
+			return errEventNotFound
+		}
+
+		if err := tx.Where("event_id = ? AND user_id = ?", event.ID, user.ID).First(&rsvp).Error; err == nil {
+			return errAlreadyRSVPd
+		}
+
+		status := models.RSVPGoing
+		if remaining := spotsLeft(tx, event); remaining != nil && *remaining == 0 {
+			status = models.RSVPWaitlisted
+		}
+
+		rsvp = models.RSVP{UserID: user.ID, EventID: event.ID, Status: status}
+		if err := tx.Create(&rsvp).Error; err != nil {
+			return err
+		}
+
+		left = spotsLeft(tx, event)
+		if status == models.RSVPWaitlisted {
+			position = waitlistPosition(tx, rsvp)
+		}
+		return nil
+	})
+
+	switch {
+	case errors.Is(err, errEventNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errAlreadyRSVPd):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if left != nil {
+		ws.GlobalHub.BroadcastEvent("rsvp_update", gin.H{
+			"id":         rsvp.EventID,
+			"spots_left": *left,
+		})
+	}
+
+	resp := gin.H{
+		"status":     rsvp.Status,
+		"spots_left": left,
+	}
+	if position > 0 {
+		resp["waitlist_position"] = position
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+func CancelRSVP(c *gin.Context) {
+	user, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	id := c.Param("id")
+
+	var promoted *models.RSVP
+	var left *int
+	var eventID uint
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var event models.Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, id).Error; err != nil {
+			return errEventNotFound
+		}
+		eventID = event.ID
+
+		var rsvp models.RSVP
+		if err := tx.Where("event_id = ? AND user_id = ?", event.ID, user.ID).First(&rsvp).Error; err != nil {
+			return errNoRSVP
+		}
+		if err := tx.Delete(&rsvp).Error; err != nil {
+			return err
+		}
+
+		// A freed spot goes to the longest-waiting student
+		if rsvp.Status == models.RSVPGoing && event.Capacity != nil {
+			var next models.RSVP
+			err := tx.Where("event_id = ? AND status = ?", event.ID, models.RSVPWaitlisted).
+				Order("created_at ASC, id ASC").
+				First(&next).Error
+			if err == nil {
+				if err := tx.Model(&next).Update("status", models.RSVPGoing).Error; err != nil {
+					return err
+				}
+				promoted = &next
+			}
+		}
+
+		left = spotsLeft(tx, event)
+		return nil
+	})
+
+	switch {
+	case errors.Is(err, errEventNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errNoRSVP):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if promoted != nil {
+		var promotedUser models.User
+		database.DB.First(&promotedUser, promoted.UserID)
+		ws.GlobalHub.BroadcastEvent("rsvp_promoted", gin.H{
+			"id":        eventID,
+			"user_id":   promoted.UserID,
+			"user_name": promotedUser.Name,
+		})
+	}
+	if left != nil {
+		ws.GlobalHub.BroadcastEvent("rsvp_update", gin.H{
+			"id":         eventID,
+			"spots_left": *left,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "RSVP cancelled", "spots_left": left})
+}
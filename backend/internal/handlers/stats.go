@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/ws"
+)
+
+type dayCount struct {
+	Day   string `json:"day"`
+	Count int    `json:"count"`
+}
+
+type categoryCount struct {
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+}
+
+type creatorCount struct {
+	CreatorName  string `json:"creator_name"`
+	CreatorEmail string `json:"creator_email"`
+	Count        int    `json:"count"`
+}
+
+type hourCount struct {
+	Hour  int `json:"hour"`
+	Count int `json:"count"`
+}
+
+type moderationStats struct {
+	Total         int     `json:"total"`
+	Approved      int     `json:"approved"`
+	Rejected      int     `json:"rejected"`
+	Pending       int     `json:"pending"`
+	Hidden        int     `json:"hidden"`
+	ApprovalRate  float64 `json:"approval_rate"`
+	RejectionRate float64 `json:"rejection_rate"`
+}
+
+type connectionStats struct {
+	Current int        `json:"current"`
+	Peak    int        `json:"peak"`
+	PeakAt  *time.Time `json:"peak_at"` // start of the hour the peak was seen in
+}
+
+// AdminGetStats reports activity for events created in the from/to range
+// (default: the last 30 days). Days and hours are in Toronto time.
+func AdminGetStats(c *gin.Context) {
+	from, to, ok := parseRange(c)
+	if !ok {
+		return
+	}
+
+	var perDay []dayCount
+	var perCategory []categoryCount
+	var topCreators []creatorCount
+	var peakHours []hourCount
+	var firstVerification struct {
+		MedianSeconds *float64
+	}
+	var moderation moderationStats
+	var peak struct {
+		Peak        int
+		BucketStart *time.Time
+	}
+
+	queries := []struct {
+		dest  interface{}
+		query string
+	}{
+		{&perDay, `
+			SELECT to_char(date_trunc('day', created_at AT TIME ZONE 'America/Toronto'), 'YYYY-MM-DD') as day, COUNT(*) as count
+			FROM events WHERE created_at >= ? AND created_at < ?
+			GROUP BY 1 ORDER BY 1
+		`},
+		{&perCategory, `
+			SELECT category, COUNT(*) as count
+			FROM events WHERE created_at >= ? AND created_at < ?
+			GROUP BY category ORDER BY count DESC
+		`},
+		{&firstVerification, `
+			SELECT percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM fv.first_at - e.created_at)) as median_seconds
+			FROM events e
+			JOIN (SELECT event_id, MIN(created_at) as first_at FROM verifications GROUP BY event_id) fv ON fv.event_id = e.id
+			WHERE e.created_at >= ? AND e.created_at < ?
+		`},
+		{&moderation, `
+			SELECT
+				COUNT(*) as total,
+				COUNT(*) FILTER (WHERE moderation_status = 'approved') as approved,
+				COUNT(*) FILTER (WHERE moderation_status = 'rejected') as rejected,
+				COUNT(*) FILTER (WHERE moderation_status = 'pending') as pending,
+				COUNT(*) FILTER (WHERE moderation_status = 'hidden') as hidden
+			FROM events WHERE created_at >= ? AND created_at < ?
+		`},
+		{&topCreators, `
+			SELECT MAX(creator_name) as creator_name, LOWER(creator_email) as creator_email, COUNT(*) as count
+			FROM events
+			WHERE created_at >= ? AND created_at < ? AND creator_email <> ''
+			GROUP BY LOWER(creator_email) ORDER BY count DESC LIMIT 10
+		`},
+		{&peakHours, `
+			SELECT EXTRACT(HOUR FROM start_time AT TIME ZONE 'America/Toronto')::int as hour, COUNT(*) as count
+			FROM events WHERE created_at >= ? AND created_at < ?
+			GROUP BY 1 ORDER BY 1
+		`},
+		{&peak, `
+			SELECT peak, bucket_start FROM connection_samples
+			WHERE bucket_start >= ? AND bucket_start < ?
+			ORDER BY peak DESC, bucket_start ASC LIMIT 1
+		`},
+	}
+	for _, q := range queries {
+		if err := database.DB.Raw(q.query, from, to).Scan(q.dest).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if moderation.Total > 0 {
+		moderation.ApprovalRate = float64(moderation.Approved) / float64(moderation.Total)
+		moderation.RejectionRate = float64(moderation.Rejected) / float64(moderation.Total)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":                                 from,
+		"to":                                   to,
+		"events_per_day":                       perDay,
+		"events_per_category":                  perCategory,
+		"median_seconds_to_first_verification": firstVerification.MedianSeconds,
+		"moderation":                           moderation,
+		"top_creators":                         topCreators,
+		"peak_hours":                           peakHours,
+		"websocket": connectionStats{
+			Current: ws.GlobalHub.ClientCount(),
+			Peak:    peak.Peak,
+			PeakAt:  peak.BucketStart,
+		},
+	})
+}
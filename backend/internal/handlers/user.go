@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parsaabbasian/unispot/backend/internal/auth"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+)
+
+// currentUser resolves the signed-in student from their verified Supabase
+// session, creating the user on first sight. It returns nil for anonymous
+// requests and tokens that don't verify.
+func currentUser(c *gin.Context) *models.User {
+	identity := auth.FromRequest(c)
+	if identity == nil {
+		return nil
+	}
+
+	user := models.User{Email: identity.Email}
+	if err := database.DB.Where(models.User{Email: identity.Email}).
+		Attrs(models.User{Name: identity.Name}).
+		FirstOrCreate(&user).Error; err != nil {
+		return nil
+	}
+	return &user
+}
+
+// requireUser is currentUser for endpoints that need a signed-in student.
+func requireUser(c *gin.Context) (*models.User, bool) {
+	user := currentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign in to continue"})
+		return nil, false
+	}
+	return user, true
+}
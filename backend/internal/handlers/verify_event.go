@@ -1,35 +1,78 @@
 package handlers
 
 import (
+	"fmt"
+	"log"
+	"math"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/parsaabbasian/unispot/backend/internal/config"
 	"github.com/parsaabbasian/unispot/backend/internal/database"
 	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"github.com/parsaabbasian/unispot/backend/internal/services/confidence"
+	"github.com/parsaabbasian/unispot/backend/internal/services/reputation"
 	"github.com/parsaabbasian/unispot/backend/internal/ws"
 )
 
 type VerifyEventRequest struct {
-	UserName  string `json:"user_name"`
-	UserEmail string `json:"user_email"`
+	UserName  string   `json:"user_name"`
+	UserEmail string   `json:"user_email"`
+	Latitude  *float64 `json:"lat"`
+	Longitude *float64 `json:"lng"`
+	Accuracy  float64  `json:"accuracy"` // GPS accuracy radius in metres
 }
 
-func VerifyEvent(c *gin.Context) {
-	id := c.Param("id")
-	ipAddress := c.ClientIP()
+// verifyMaxDistance is how far (in metres) a verifier may be from the event.
+// Up to verifyMaxAccuracy metres of reported GPS uncertainty are given as slack.
+func verifyMaxDistance() float64 { return config.Float("VERIFY_MAX_DISTANCE_M", 150) }
+func verifyMaxAccuracy() float64 { return config.Float("VERIFY_MAX_ACCURACY_M", 100) }
 
+func VerifyEvent(c *gin.Context) {
 	var req VerifyEventRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		// Fallback for older clients or simpler requests
 		req.UserName = "Student"
 	}
 
+	verifyEvent(c, c.Param("id"), req)
+}
+
+// verifyEvent records a verification of event id from the requesting IP and writes the response.
+func verifyEvent(c *gin.Context, id interface{}, req VerifyEventRequest) {
+	ipAddress := c.ClientIP()
+
 	var event models.Event
-	if err := database.DB.First(&event, id).Error; err != nil {
+	if err := database.DB.Scopes(publicEvents).First(&event, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
 		return
 	}
 
+	if req.Latitude == nil || req.Longitude == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Your location is required to verify an event"})
+		return
+	}
+
+	// Proximity check against the event's PostGIS location
+	var probe struct {
+		Distance float64
+		Loc      string
+	}
+	if err := database.DB.Raw(
+		`SELECT ST_Distance(location, ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography) as distance, ST_AsText(location) as loc FROM events WHERE id = ?`,
+		*req.Longitude, *req.Latitude, event.ID,
+	).Scan(&probe).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	distance := probe.Distance
+	setLatLng(&event, probe.Loc)
+	accuracy := math.Max(req.Accuracy, 0)
+	if distance-math.Min(accuracy, verifyMaxAccuracy()) > verifyMaxDistance() {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("You need to be at the event to verify it (you are %.0f m away)", distance)})
+		return
+	}
+
 	// Check if this IP has already verified this event
 	var existingVerification models.Verification
 	err := database.DB.Where("event_id = ? AND ip_address = ?", event.ID, ipAddress).First(&existingVerification).Error
@@ -40,10 +83,12 @@ func VerifyEvent(c *gin.Context) {
 
 	// Double check to ensure we don't increment twice (race condition check via DB constraint)
 	verification := models.Verification{
-		EventID:   event.ID,
-		IPAddress: ipAddress,
-		UserName:  req.UserName,
-		UserEmail: req.UserEmail,
+		EventID:        event.ID,
+		IPAddress:      ipAddress,
+		UserName:       req.UserName,
+		UserEmail:      req.UserEmail,
+		DistanceMeters: distance,
+		AccuracyMeters: accuracy,
 	}
 
 	if err := database.DB.Create(&verification).Error; err != nil {
@@ -60,16 +105,26 @@ func VerifyEvent(c *gin.Context) {
 	}
 
 	newCount := event.VerifiedCount + 1
+	reputation.Record(event.CreatorEmail, reputation.VerificationReceived)
+
+	score, lastVerifiedAt, err := confidence.ForEvent(event.ID)
+	if err != nil {
+		log.Printf("Failed to compute confidence for event %d: %v", event.ID, err)
+	}
 
 	// Broadcast the update
-	ws.GlobalHub.BroadcastEvent("verify_event", gin.H{
-		"id":             event.ID,
-		"verified_count": newCount,
-		"user_name":      req.UserName,
+	ws.GlobalHub.BroadcastTopic(ws.EventTopic(&event), "verify_event", gin.H{
+		"id":               event.ID,
+		"verified_count":   newCount,
+		"user_name":        req.UserName,
+		"confidence":       score,
+		"last_verified_at": lastVerifiedAt,
 	})
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":        "Event verified successfully",
-		"verified_count": newCount,
+		"message":          "Event verified successfully",
+		"verified_count":   newCount,
+		"confidence":       score,
+		"last_verified_at": lastVerifiedAt,
 	})
 }
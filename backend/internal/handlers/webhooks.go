@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"github.com/parsaabbasian/unispot/backend/internal/services/webhook"
+)
+
+// WebhookRequest creates or edits a subscription. On PATCH, omitted fields
+// are left alone. Discord subscriptions need the channel's webhook URL;
+// Slack ones need a channel ID and a bot token with chat:write.
+type WebhookRequest struct {
+	URL          *string   `json:"url"`
+	Description  *string   `json:"description"`
+	Format       *string   `json:"format"`      // json (default), discord or slack
+	EventTypes   *[]string `json:"event_types"` // empty means every type
+	Category     *string   `json:"category"`    // empty means every category
+	Channel      *string   `json:"channel"`
+	Token        *string   `json:"token"`
+	IsActive     *bool     `json:"is_active"`
+	RotateSecret bool      `json:"rotate_secret"`
+}
+
+// slackAPI is where Slack subscriptions post unless given another URL.
+const slackAPI = "https://slack.com/api"
+
+// webhookWithSecret is only returned when a secret is created or rotated.
+type webhookWithSecret struct {
+	models.WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+// deliveryLog shows the payload as JSON rather than an escaped string.
+type deliveryLog struct {
+	models.WebhookDelivery
+	Payload json.RawMessage `json:"payload"`
+}
+
+func validWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && u.Host != "" && (u.Scheme == "https" || u.Scheme == "http")
+}
+
+// applyWebhookRequest copies the fields set in req onto sub and checks the
+// result is deliverable.
+func applyWebhookRequest(sub *models.WebhookSubscription, req *WebhookRequest) error {
+	if req.URL != nil {
+		sub.URL = strings.TrimSpace(*req.URL)
+	}
+	if req.Description != nil {
+		sub.Description = *req.Description
+	}
+	if req.Format != nil {
+		switch *req.Format {
+		case models.WebhookJSON, models.WebhookDiscord, models.WebhookSlack:
+			sub.Format = *req.Format
+		default:
+			return errors.New("format must be json, discord or slack")
+		}
+	}
+	if req.Category != nil {
+		sub.Category = strings.TrimSpace(*req.Category)
+	}
+	if req.Channel != nil {
+		sub.Channel = strings.TrimSpace(*req.Channel)
+	}
+	if req.Token != nil {
+		sub.Token = strings.TrimSpace(*req.Token)
+	}
+	if req.EventTypes != nil {
+		types := pq.StringArray{}
+		for _, t := range *req.EventTypes {
+			if !webhook.ValidType(t) {
+				return errors.New("unknown event type: " + t)
+			}
+			types = append(types, t)
+		}
+		sub.EventTypes = types
+	}
+	if req.IsActive != nil {
+		sub.IsActive = *req.IsActive
+	}
+
+	if sub.Format == models.WebhookSlack {
+		if sub.URL == "" {
+			sub.URL = slackAPI
+		}
+		if sub.Channel == "" || sub.Token == "" {
+			return errors.New("slack webhooks need a channel and a token")
+		}
+	}
+	if !validWebhookURL(sub.URL) {
+		return errors.New("url must be an http(s) URL")
+	}
+	return nil
+}
+
+// AdminGetWebhooks lists subscriptions and the event types they can use.
+func AdminGetWebhooks(c *gin.Context) {
+	subs := []models.WebhookSubscription{}
+	if err := database.DB.Order("id").Find(&subs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"webhooks": subs, "event_types": webhook.Types})
+}
+
+// AdminCreateWebhook registers a URL and returns its signing secret. The
+// secret isn't shown again; rotate it if it's lost.
+func AdminCreateWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sub := models.WebhookSubscription{
+		Format:     models.WebhookJSON,
+		EventTypes: pq.StringArray{},
+		IsActive:   true,
+		Secret:     webhook.NewSecret(),
+	}
+	if err := applyWebhookRequest(&sub, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.DB.Create(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhookWithSecret{sub, sub.Secret})
+}
+
+// AdminUpdateWebhook edits a subscription, optionally rotating its secret.
+func AdminUpdateWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var sub models.WebhookSubscription
+	if err := database.DB.First(&sub, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	if err := applyWebhookRequest(&sub, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.RotateSecret {
+		sub.Secret = webhook.NewSecret()
+	}
+	if err := database.DB.Save(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.RotateSecret {
+		c.JSON(http.StatusOK, webhookWithSecret{sub, sub.Secret})
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+// AdminDeleteWebhook removes a subscription and its delivery log.
+func AdminDeleteWebhook(c *gin.Context) {
+	var sub models.WebhookSubscription
+	if err := database.DB.First(&sub, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	if err := database.DB.Where("subscription_id = ?", sub.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.DB.Delete(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// AdminGetWebhookDeliveries returns a subscription's deliveries, newest
+// first. Filter with ?status=pending|delivered|dead and page with ?before=<id>.
+func AdminGetWebhookDeliveries(c *gin.Context) {
+	var sub models.WebhookSubscription
+	if err := database.DB.First(&sub, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	query := database.DB.Where("subscription_id = ?", sub.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if before, err := strconv.Atoi(c.Query("before")); err == nil && before > 0 {
+		query = query.Where("id < ?", before)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("id DESC").Limit(limit + 1).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var nextBefore *uint
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+		nextBefore = &deliveries[limit-1].ID
+	}
+
+	logs := make([]deliveryLog, len(deliveries))
+	for i, d := range deliveries {
+		logs[i] = deliveryLog{d, json.RawMessage(d.Payload)}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries":  logs,
+		"next_before": nextBefore,
+	})
+}
+
+// AdminRedeliverWebhook queues a delivery again, e.g. a dead letter once the
+// receiver is fixed.
+func AdminRedeliverWebhook(c *gin.Context) {
+	var delivery models.WebhookDelivery
+	if err := database.DB.First(&delivery, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+	if err := webhook.Redeliver(delivery.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Delivery queued"})
+}
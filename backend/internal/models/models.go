@@ -2,38 +2,306 @@ package models
 
 import (
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type Event struct {
-	ID            uint      `gorm:"primaryKey" json:"id"`
-	Title         string    `gorm:"not null" json:"title"`
-	Description   string    `json:"description"`
-	Category      string    `gorm:"not null" json:"category"`
-	Location      string    `gorm:"type:geography(POINT);not null" json:"location"` // ST_AsText format
-	StartTime     time.Time `gorm:"not null" json:"start_time"`
-	EndTime       time.Time `gorm:"not null" json:"end_time"`
-	VerifiedCount int       `gorm:"default:0" json:"verified_count"`
-	IsApproved    bool      `gorm:"default:false" json:"is_approved"` // Admin approval via Supabase
-	CreatorName   string    `json:"creator_name"`
-	CreatorEmail  string    `json:"creator_email"`
-	Verifiers     []string  `gorm:"-" json:"verifiers"`
-	Latitude      float64   `gorm:"-" json:"lat"`
-	Longitude     float64   `gorm:"-" json:"lng"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID                 uint           `gorm:"primaryKey" json:"id"`
+	Title              string         `gorm:"not null" json:"title"`
+	Description        string         `json:"description"`
+	Category           string         `gorm:"not null" json:"category"`
+	Location           string         `gorm:"type:geography(POINT);not null" json:"location"` // ST_AsText format
+	StartTime          time.Time      `gorm:"not null" json:"start_time"`
+	EndTime            time.Time      `gorm:"not null" json:"end_time"`
+	VerifiedCount      int            `gorm:"default:0" json:"verified_count"`
+	LastVerifiedAt     *time.Time     `gorm:"-" json:"last_verified_at"`
+	Confidence         float64        `gorm:"-" json:"confidence"`          // 0–1, decays as verifications age; see services/confidence
+	Reactions          map[string]int `gorm:"-" json:"reactions,omitempty"` // emoji -> count
+	Capacity           *int           `json:"capacity"`                     // nil means unlimited
+	SpotsLeft          *int           `gorm:"-" json:"spots_left"`
+	IsApproved         bool           `gorm:"default:false" json:"is_approved"` // Admin approval via Supabase
+	IsHidden           bool           `gorm:"default:false" json:"is_hidden"`   // Auto-hidden after too many disputes
+	DisputeCount       int            `gorm:"default:0" json:"dispute_count"`
+	ModerationStatus   string         `gorm:"index" json:"moderation_status"` // See services/moderation; drives IsApproved/IsHidden
+	ModerationReason   string         `json:"moderation_reason,omitempty"`
+	CreatorName        string         `json:"creator_name"`
+	CreatorEmail       string         `json:"-"`                                     // Private; admin views add it back
+	OrganizerTokenHash string         `json:"-"`                                     // SHA-256 of the token only the creator gets; see handlers/checkin.go
+	CreatorReputation  *float64       `gorm:"-" json:"creator_reputation,omitempty"` // Admin views only
+	Followed           bool           `gorm:"-" json:"followed,omitempty"`           // Matches a follow, in personalized results
+	Verifiers          []string       `gorm:"-" json:"verifiers"`
+	Latitude           float64        `gorm:"-" json:"lat"`
+	Longitude          float64        `gorm:"-" json:"lng"`
+	ArchivedAt         *time.Time     `gorm:"index" json:"archived_at,omitempty"` // Set once the event ends; nil while live
+	CreatedAt          time.Time      `json:"created_at"`
 }
 
+type User struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `gorm:"not null;uniqueIndex" json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RSVP statuses. Waitlisted RSVPs are promoted in created_at order.
+const (
+	RSVPGoing      = "going"
+	RSVPWaitlisted = "waitlisted"
+)
+
 type RSVP struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `gorm:"not null" json:"user_id"`
-	EventID   uint      `gorm:"not null" json:"event_id"`
+	UserID    uint      `gorm:"not null;index:idx_rsvp_event_user,unique" json:"user_id"`
+	EventID   uint      `gorm:"not null;index:idx_rsvp_event_user,unique" json:"event_id"`
+	Status    string    `gorm:"not null;default:going" json:"status"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
 type Verification struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	EventID        uint      `gorm:"not null;index:idx_event_ip,unique" json:"event_id"`
+	IPAddress      string    `gorm:"not null;index:idx_event_ip,unique" json:"ip_address"`
+	UserName       string    `json:"user_name"`
+	UserEmail      string    `json:"user_email"`
+	DistanceMeters float64   `json:"distance_m"` // metres from the event when verified
+	AccuracyMeters float64   `json:"accuracy_m"` // reported GPS accuracy
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type Attendance struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	EventID     uint      `gorm:"not null;index:idx_attendance_event_user,unique" json:"event_id"`
+	UserID      uint      `gorm:"not null;index:idx_attendance_event_user,unique" json:"user_id"`
+	UserName    string    `json:"user_name"`
+	UserEmail   string    `json:"user_email"`
+	IPAddress   string    `json:"ip_address"`
+	CheckedInAt time.Time `gorm:"not null" json:"checked_in_at"`
+}
+
+// Dispute reason codes.
+const (
+	DisputeEnded         = "ended"
+	DisputeFake          = "fake"
+	DisputeWrongLocation = "wrong_location"
+	DisputeSpam          = "spam"
+)
+
+type Dispute struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	EventID   uint      `gorm:"not null;index:idx_event_ip,unique" json:"event_id"`
-	IPAddress string    `gorm:"not null;index:idx_event_ip,unique" json:"ip_address"`
-	UserName  string    `json:"user_name"`
-	UserEmail string    `json:"user_email"`
+	EventID   uint      `gorm:"not null;index:idx_dispute_event_ip,unique" json:"event_id"`
+	IPAddress string    `gorm:"not null;index:idx_dispute_event_ip,unique" json:"ip_address"`
+	UserEmail string    `gorm:"index" json:"user_email"`
+	Reason    string    `gorm:"not null" json:"reason"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+type ModerationLog struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	EventID    uint      `gorm:"not null;index" json:"event_id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `gorm:"not null" json:"to_status"`
+	Reason     string    `json:"reason"`
+	Actor      string    `json:"actor"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type RateLimitBucket struct {
+	Key       string    `gorm:"primaryKey"`
+	Tokens    float64   `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"not null;index"`
+}
+
+// ConnectionSample is the peak WebSocket concurrency seen during one hour.
+type ConnectionSample struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	BucketStart time.Time `gorm:"not null;uniqueIndex" json:"bucket_start"`
+	Peak        int       `gorm:"not null" json:"peak"`
+}
+
+// CreatorReputation is updated incrementally by services/reputation.
+type CreatorReputation struct {
+	Email         string    `gorm:"primaryKey" json:"email"`
+	Score         float64   `gorm:"not null;default:0" json:"score"`
+	Verifications int       `gorm:"not null;default:0" json:"verifications"`
+	Disputes      int       `gorm:"not null;default:0" json:"disputes"`
+	Approvals     int       `gorm:"not null;default:0" json:"approvals"`
+	Rejections    int       `gorm:"not null;default:0" json:"rejections"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Comment is a message in an event's thread. Hidden comments stay in the
+// table for admins but are left out of the public thread.
+type Comment struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	EventID    uint      `gorm:"not null;index:idx_comment_event_created" json:"event_id"`
+	UserID     uint      `gorm:"not null;index" json:"-"`
+	AuthorName string    `json:"author_name"`
+	Body       string    `gorm:"not null" json:"body"`
+	IsHidden   bool      `gorm:"not null;default:false" json:"is_hidden"`
+	HideReason string    `json:"hide_reason,omitempty"`
+	IPAddress  string    `json:"-"`
+	CreatedAt  time.Time `gorm:"index:idx_comment_event_created" json:"created_at"`
+}
+
+// Reaction emoji.
+const (
+	ReactionFire  = "🔥"
+	ReactionLike  = "👍"
+	ReactionYum   = "😋"
+	ReactionCross = "❌"
+)
+
+// Reaction is one person's emoji on an event; a new tap replaces the old one.
+type Reaction struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	EventID   uint      `gorm:"not null;index:idx_reaction_event_ip,unique" json:"event_id"`
+	IPAddress string    `gorm:"not null;index:idx_reaction_event_ip,unique" json:"-"`
+	UserEmail string    `gorm:"index" json:"-"`
+	Emoji     string    `gorm:"not null" json:"emoji"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EventView records that a signed-in user opened an event's details. It feeds
+// their recommendations.
+type EventView struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	EventID   uint      `gorm:"not null;index:idx_view_event_user,unique" json:"event_id"`
+	UserID    uint      `gorm:"not null;index:idx_view_event_user,unique" json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserPreference holds what a student follows and mutes. Creators (people or
+// clubs) match an event's creator name; buildings are campus.Building slugs.
+type UserPreference struct {
+	UserID             uint           `gorm:"primaryKey" json:"-"`
+	FollowedCategories pq.StringArray `gorm:"type:text[]" json:"followed_categories"`
+	FollowedCreators   pq.StringArray `gorm:"type:text[]" json:"followed_creators"`
+	FollowedBuildings  pq.StringArray `gorm:"type:text[]" json:"followed_buildings"`
+	MutedCategories    pq.StringArray `gorm:"type:text[]" json:"muted_categories"`
+	HomeCampus         string         `json:"home_campus"` // campus.Campus slug; empty means campus.DefaultCampus
+	DigestFrequency    string         `gorm:"not null;default:off;index" json:"digest_frequency"`
+	LastDigestAt       *time.Time     `json:"last_digest_at,omitempty"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+}
+
+// Digest email frequencies.
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// PushSubscription is a browser's Web Push endpoint. ExpiredAt is set once the
+// push service reports it gone; expired subscriptions are never sent to again.
+type PushSubscription struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"-"`
+	Endpoint  string     `gorm:"not null;uniqueIndex" json:"endpoint"`
+	P256dh    string     `gorm:"not null" json:"-"`
+	Auth      string     `gorm:"not null" json:"-"`
+	ExpiredAt *time.Time `json:"expired_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// AlertRule asks for a push when a matching event is published: optionally in
+// one category, within RadiusM of a point, and only while the local time of
+// day is between StartMinute and EndMinute (minutes after midnight; the window
+// wraps past midnight when StartMinute > EndMinute).
+type AlertRule struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"not null;index" json:"-"`
+	Category    string    `json:"category"` // empty matches every category
+	Building    string    `json:"building,omitempty"`
+	Location    string    `gorm:"type:geography(POINT);not null" json:"-"`
+	Latitude    float64   `gorm:"-" json:"lat"`
+	Longitude   float64   `gorm:"-" json:"lng"`
+	RadiusM     float64   `gorm:"not null" json:"radius_m"`
+	StartMinute *int      `json:"start_minute"`
+	EndMinute   *int      `json:"end_minute"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Push notification delivery states.
+const (
+	PushPending = "pending"
+	PushSent    = "sent"
+	PushFailed  = "failed"  // gave up after repeated errors
+	PushExpired = "expired" // subscription was gone
+)
+
+// PushNotification is one queued push of an event to a subscription.
+type PushNotification struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	EventID        uint      `gorm:"not null;index:idx_push_event_sub,unique" json:"event_id"`
+	SubscriptionID uint      `gorm:"not null;index:idx_push_event_sub,unique" json:"subscription_id"`
+	RuleID         uint      `json:"rule_id"`
+	Status         string    `gorm:"not null;default:pending;index:idx_push_due" json:"status"`
+	Attempts       int       `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time `gorm:"not null;index:idx_push_due" json:"next_attempt_at"`
+	LastError      string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Webhook formats. JSON subscriptions get the signed generic payload; the
+// others get a chat message that is edited as the event is verified.
+const (
+	WebhookJSON    = "json"
+	WebhookDiscord = "discord"
+	WebhookSlack   = "slack"
+)
+
+// WebhookSubscription is an admin-registered URL that is POSTed event
+// lifecycle changes. An empty EventTypes means every type; an empty Category
+// means every category.
+type WebhookSubscription struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	URL         string         `gorm:"not null" json:"url"` // Discord webhook URL, or the Slack API base
+	Description string         `json:"description"`
+	Format      string         `gorm:"not null;default:json" json:"format"`
+	EventTypes  pq.StringArray `gorm:"type:text[]" json:"event_types"`
+	Category    string         `gorm:"not null;default:''" json:"category"`
+	Channel     string         `json:"channel,omitempty"` // Slack channel ID
+	Secret      string         `gorm:"not null" json:"-"` // HMAC-SHA256 signing key
+	Token       string         `json:"-"`                 // Slack bot token
+	IsActive    bool           `gorm:"not null;default:true" json:"is_active"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// Webhook delivery states.
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookDead      = "dead" // gave up; can be redelivered by an admin
+)
+
+// WebhookDelivery is one queued POST of an event to a subscription.
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint       `gorm:"not null;index" json:"subscription_id"`
+	EventID        uint       `gorm:"index" json:"event_id,omitempty"`
+	EventType      string     `gorm:"not null" json:"event_type"`
+	Payload        string     `gorm:"type:jsonb;not null" json:"-"`
+	Status         string     `gorm:"not null;default:pending;index:idx_webhook_due" json:"status"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"not null;index:idx_webhook_due" json:"next_attempt_at"`
+	LastStatus     int        `json:"last_status,omitempty"` // HTTP status of the last attempt
+	LastError      string     `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// WebhookMessage remembers the chat message posted for an event, so later
+// verifications edit it instead of posting again.
+type WebhookMessage struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint      `gorm:"not null;index:idx_webhook_message,unique" json:"subscription_id"`
+	EventID        uint      `gorm:"not null;index:idx_webhook_message,unique" json:"event_id"`
+	MessageID      string    `gorm:"not null" json:"message_id"` // Discord message ID or Slack ts
+	Channel        string    `json:"channel,omitempty"`          // Slack channel the message is in
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Buckets idle this long are full again and can be forgotten.
+const idleBucketTTL = 24 * time.Hour
+
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// MemoryStore keeps buckets in process memory.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryBucket), lastSweep: time.Now()}
+}
+
+func (s *MemoryStore) Take(keys []string, limit Limit) (Decision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	tokens := make([]float64, len(keys))
+	for i, key := range keys {
+		b, ok := s.buckets[key]
+		if !ok {
+			b = &memoryBucket{tokens: float64(limit.Requests), updatedAt: now}
+			s.buckets[key] = b
+		}
+		tokens[i] = refill(b.tokens, now.Sub(b.updatedAt), limit)
+	}
+
+	d := take(tokens, limit)
+	for i, key := range keys {
+		s.buckets[key].tokens = tokens[i]
+		s.buckets[key].updatedAt = now
+	}
+	return d, nil
+}
+
+// sweep drops idle buckets at most once an hour. Callers hold s.mu.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Hour {
+		return
+	}
+	for key, b := range s.buckets {
+		if now.Sub(b.updatedAt) > idleBucketTTL {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"log"
+	"time"
+
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresStore keeps buckets in the rate_limit_buckets table so every API
+// instance draws from the same buckets.
+type PostgresStore struct{}
+
+func NewPostgresStore() *PostgresStore {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		for range ticker.C {
+			result := database.DB.Where("updated_at < ?", time.Now().Add(-idleBucketTTL)).Delete(&models.RateLimitBucket{})
+			if result.Error != nil {
+				log.Printf("Rate limit bucket cleanup failed: %v", result.Error)
+			}
+		}
+	}()
+	return &PostgresStore{}
+}
+
+func (PostgresStore) Take(keys []string, limit Limit) (Decision, error) {
+	var d Decision
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		buckets := make([]models.RateLimitBucket, len(keys))
+		for i, key := range keys {
+			buckets[i] = models.RateLimitBucket{Key: key, Tokens: float64(limit.Requests), UpdatedAt: now}
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&buckets).Error; err != nil {
+			return err
+		}
+
+		// Lock in key order so concurrent requests can't deadlock
+		var locked []models.RateLimitBucket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key IN ?", keys).Order("key").Find(&locked).Error; err != nil {
+			return err
+		}
+
+		tokens := make([]float64, len(locked))
+		for i, b := range locked {
+			tokens[i] = refill(b.Tokens, now.Sub(b.UpdatedAt), limit)
+		}
+		d = take(tokens, limit)
+		for i := range locked {
+			if err := tx.Model(&locked[i]).Updates(map[string]interface{}{"tokens": tokens[i], "updated_at": now}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return d, err
+}
@@ -0,0 +1,172 @@
+// Package ratelimit throttles write endpoints with token buckets keyed per
+// client IP and per signed-in user.
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parsaabbasian/unispot/backend/internal/auth"
+	"github.com/parsaabbasian/unispot/backend/internal/config"
+)
+
+// Limit allows Requests requests in a burst, refilling a full bucket every Per.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseLimit parses limits written as "requests/period", e.g. "10/1m".
+func ParseLimit(s string) (Limit, error) {
+	n, per, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q: want requests/period", s)
+	}
+	requests, err := strconv.Atoi(n)
+	if err != nil || requests < 1 {
+		return Limit{}, fmt.Errorf("rate limit %q: bad request count", s)
+	}
+	period, err := time.ParseDuration(per)
+	if err != nil || period <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: bad period", s)
+	}
+	return Limit{Requests: requests, Per: period}, nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+}
+
+// ratePerSecond is how fast tokens refill.
+func (l Limit) ratePerSecond() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// Decision is the outcome of taking a token from a request's buckets.
+type Decision struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration // until the next token, when not allowed
+	Reset      time.Duration // until the buckets are full again
+}
+
+// refill tops a bucket up for the time elapsed since it was last used.
+func refill(tokens float64, elapsed time.Duration, limit Limit) float64 {
+	return math.Min(float64(limit.Requests), tokens+elapsed.Seconds()*limit.ratePerSecond())
+}
+
+// take applies the token-bucket arithmetic shared by every Store to buckets
+// that have been refilled: a token comes out of each of them only if every
+// one has a token to give, so a denied request costs nothing.
+func take(buckets []float64, limit Limit) Decision {
+	rate := limit.ratePerSecond()
+	d := Decision{Allowed: true, Remaining: limit.Requests}
+	for _, tokens := range buckets {
+		if tokens < 1 {
+			d.Allowed = false
+			d.RetryAfter = max(d.RetryAfter, time.Duration((1-tokens)/rate*float64(time.Second)))
+		}
+	}
+	for i := range buckets {
+		if d.Allowed {
+			buckets[i]--
+		}
+		d.Remaining = min(d.Remaining, int(buckets[i]))
+		d.Reset = max(d.Reset, time.Duration((float64(limit.Requests)-buckets[i])/rate*float64(time.Second)))
+	}
+	return d
+}
+
+// Store holds bucket state. MemoryStore suits a single instance;
+// PostgresStore shares buckets across instances. Take refills the buckets
+// named by keys and takes a token from each of them, or from none.
+type Store interface {
+	Take(keys []string, limit Limit) (Decision, error)
+}
+
+type Limiter struct {
+	Store Store
+}
+
+// Default is the limiter used by the API routes. Configure swaps its store.
+var Default = &Limiter{Store: NewMemoryStore()}
+
+// Configure picks the store named by RATE_LIMIT_BACKEND ("memory" or
+// "postgres"). Call it after the database is connected.
+func Configure() {
+	switch backend := config.String("RATE_LIMIT_BACKEND", "memory"); backend {
+	case "postgres":
+		Default.Store = NewPostgresStore()
+	case "memory":
+		Default.Store = NewMemoryStore()
+	default:
+		log.Printf("Unknown RATE_LIMIT_BACKEND %q, using memory", backend)
+		Default.Store = NewMemoryStore()
+	}
+}
+
+// limitFor returns the limit for route, overridden by RATE_LIMIT_<ROUTE>
+// (e.g. RATE_LIMIT_CREATE_EVENT=5/10m) when set.
+func limitFor(route string, def Limit) Limit {
+	key := "RATE_LIMIT_" + strings.ToUpper(route)
+	v := config.String(key, "")
+	if v == "" {
+		return def
+	}
+	limit, err := ParseLimit(v)
+	if err != nil {
+		log.Printf("Ignoring %s: %v", key, err)
+		return def
+	}
+	return limit
+}
+
+// Allow takes a token from the route's per-IP bucket and, for signed-in
+// users, their per-user bucket; if either is empty neither is charged.
+// RATE_LIMIT_<ROUTE> overrides limit when set. It writes the X-RateLimit-*
+// headers and, when denied, a 429 response; callers should stop if it
+// returns false. Store failures let the request through.
+func (l *Limiter) Allow(c *gin.Context, route string, limit Limit) bool {
+	limit = limitFor(route, limit)
+	keys := []string{"ip:" + route + ":" + c.ClientIP()}
+	if identity := auth.FromRequest(c); identity != nil {
+		keys = append(keys, "user:"+route+":"+identity.Email)
+	}
+
+	result, err := l.Store.Take(keys, limit)
+	if err != nil {
+		log.Printf("Rate limit store error: %v", err)
+		return true
+	}
+
+	c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Requests))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(result.Reset.Seconds()))))
+	if result.Allowed {
+		return true
+	}
+
+	retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Too many requests, slow down",
+		"retry_after": retryAfter,
+	})
+	return false
+}
+
+// Route returns middleware applying limit (or its RATE_LIMIT_<ROUTE> override) to a route.
+func (l *Limiter) Route(route string, def Limit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !l.Allow(c, route, def) {
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,63 @@
+// Package checkin issues and validates the rotating tokens organizers show
+// as a QR code so attendees can prove they were at an event.
+package checkin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parsaabbasian/unispot/backend/internal/config"
+)
+
+var ErrInvalidToken = errors.New("Check-in code is invalid or has expired")
+
+// Rotation is how long a token stays current. The previous token is still
+// accepted so a scan that straddles a rotation doesn't fail.
+func Rotation() time.Duration {
+	rotation := config.Duration("CHECKIN_TOKEN_ROTATION", 30*time.Second)
+	if rotation < time.Second {
+		return 30 * time.Second
+	}
+	return rotation
+}
+
+func sign(eventID uint, window int64) string {
+	mac := hmac.New(sha256.New, config.Secret("CHECKIN_SECRET"))
+	fmt.Fprintf(mac, "%d:%d", eventID, window)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// Issue returns the token for eventID that is current at now, and when it rotates.
+func Issue(eventID uint, now time.Time) (string, time.Time) {
+	rotation := Rotation()
+	window := now.Unix() / int64(rotation.Seconds())
+	expiresAt := time.Unix((window+1)*int64(rotation.Seconds()), 0).UTC()
+	return fmt.Sprintf("%d.%s", window, sign(eventID, window)), expiresAt
+}
+
+// Validate checks that token was issued for eventID in the current or previous window.
+func Validate(eventID uint, token string, now time.Time) error {
+	windowStr, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return ErrInvalidToken
+	}
+	window, err := strconv.ParseInt(windowStr, 10, 64)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	current := now.Unix() / int64(Rotation().Seconds())
+	if window != current && window != current-1 {
+		return ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(sig), []byte(sign(eventID, window))) {
+		return ErrInvalidToken
+	}
+	return nil
+}
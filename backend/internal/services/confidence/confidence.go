@@ -0,0 +1,70 @@
+// Package confidence estimates how likely an event is still happening, from
+// how recently it was verified, how often it was disputed and who posted it.
+package confidence
+
+import (
+	"math"
+	"time"
+
+	"github.com/parsaabbasian/unispot/backend/internal/config"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+)
+
+// HalfLife is how long it takes a verification to lose half its weight.
+func HalfLife() time.Duration {
+	halfLife := config.Duration("CONFIDENCE_HALF_LIFE", 45*time.Minute)
+	if halfLife <= 0 {
+		return 45 * time.Minute
+	}
+	return halfLife
+}
+
+// Decay is the weight left on evidence of the given age.
+func Decay(age time.Duration) float64 {
+	if age < 0 {
+		age = 0
+	}
+	return math.Pow(0.5, age.Seconds()/HalfLife().Seconds())
+}
+
+// Score combines the evidence into a value between 0 and 1.
+//
+// decayedVerifications is the sum of Decay over each verification's age. The
+// creator's own post counts as half a verification made at startTime, and
+// their reputation shifts the evidence by up to half a verification either
+// way. Each dispute then scales the result down.
+func Score(decayedVerifications float64, startTime time.Time, disputes int, reputation float64, now time.Time) float64 {
+	evidence := decayedVerifications + 0.5*Decay(now.Sub(startTime))
+	evidence += math.Max(-0.5, math.Min(0.5, reputation/20))
+
+	score := 1 - math.Exp(-math.Max(evidence, 0))
+	score *= math.Exp(-config.Float("CONFIDENCE_DISPUTE_WEIGHT", 0.3) * float64(disputes))
+	return math.Round(score*1000) / 1000
+}
+
+// ForEvent computes the confidence and last verification time of one event.
+func ForEvent(eventID uint) (float64, *time.Time, error) {
+	var row struct {
+		StartTime            time.Time
+		DisputeCount         int
+		Reputation           float64
+		DecayedVerifications float64
+		LastVerifiedAt       *time.Time
+	}
+	err := database.DB.Raw(`
+		SELECT
+			e.start_time, e.dispute_count,
+			COALESCE((SELECT cr.score FROM creator_reputations cr WHERE cr.email = LOWER(e.creator_email)), 0) as reputation,
+			COALESCE(SUM(power(0.5, EXTRACT(EPOCH FROM now() - v.created_at) / ?)), 0) as decayed_verifications,
+			MAX(v.created_at) as last_verified_at
+		FROM events e
+		LEFT JOIN verifications v ON e.id = v.event_id
+		WHERE e.id = ?
+		GROUP BY e.id
+	`, HalfLife().Seconds(), eventID).Scan(&row).Error
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return Score(row.DecayedVerifications, row.StartTime, row.DisputeCount, row.Reputation, time.Now()), row.LastVerifiedAt, nil
+}
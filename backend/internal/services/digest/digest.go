@@ -0,0 +1,176 @@
+// Package digest emails students a daily or weekly round-up of what's live
+// and what was popular on their home campus, filtered by the categories they
+// follow and mute.
+package digest
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/parsaabbasian/unispot/backend/internal/campus"
+	"github.com/parsaabbasian/unispot/backend/internal/config"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/mail"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+)
+
+const (
+	maxUpcoming = 8
+	maxPopular  = 5
+)
+
+//go:embed templates/*
+var templateFS embed.FS
+
+var (
+	htmlTemplate = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/digest.html"))
+	textTemplate = template.Must(template.New("digest.txt").Funcs(template.FuncMap{
+		"upper": strings.ToUpper,
+	}).ParseFS(templateFS, "templates/digest.txt"))
+)
+
+// Item is one event in a digest.
+type Item struct {
+	ID            uint
+	Title         string
+	Description   string
+	Category      string
+	EndTime       time.Time
+	VerifiedCount int
+	Going         int
+	URL           string `gorm:"-"`
+	Until         string `gorm:"-"` // end time, formatted for Toronto
+}
+
+// Digest is everything the templates render.
+type Digest struct {
+	To             string
+	UserName       string
+	CampusName     string
+	Frequency      string
+	PeriodLabel    string // "daily digest" / "weekly digest"
+	SinceLabel     string // "today" / "this week"
+	Upcoming       []Item
+	Popular        []Item
+	MapURL         string
+	UnsubscribeURL string
+}
+
+// Empty reports whether there's nothing worth sending.
+func (d *Digest) Empty() bool {
+	return len(d.Upcoming) == 0 && len(d.Popular) == 0
+}
+
+func frontendURL() string {
+	return strings.TrimRight(config.String("FRONTEND_URL", "https://yorkunispot.vercel.app"), "/")
+}
+
+// Build compiles a digest for user: events live on their home campus now,
+// and the most verified and RSVPed events that started since since.
+func Build(user models.User, prefs models.UserPreference, since time.Time) (*Digest, error) {
+	home, ok := campus.FindCampus(prefs.HomeCampus)
+	if !ok {
+		home, _ = campus.FindCampus(campus.DefaultCampus)
+	}
+
+	followed := prefs.FollowedCategories
+	if followed == nil {
+		followed = pq.StringArray{}
+	}
+	muted := prefs.MutedCategories
+	if muted == nil {
+		muted = pq.StringArray{}
+	}
+
+	// Shared filter: approved, not hidden or archived, on the home campus, in
+	// a followed category (any, if none are followed) and not muted
+	filter := `
+		e.moderation_status = 'approved'
+		AND e.is_hidden = false AND e.archived_at IS NULL
+		AND ST_DWithin(e.location, ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography, ?)
+		AND (cardinality(?::text[]) = 0 OR e.category = ANY(?::text[]))
+		AND NOT (e.category = ANY(?::text[]))
+	`
+	filterArgs := []interface{}{home.Lng, home.Lat, home.RadiusM, followed, followed, muted}
+
+	d := &Digest{
+		To:             user.Email,
+		UserName:       user.Name,
+		CampusName:     home.Name,
+		Frequency:      prefs.DigestFrequency,
+		PeriodLabel:    prefs.DigestFrequency + " digest",
+		SinceLabel:     "today",
+		MapURL:         frontendURL(),
+		UnsubscribeURL: UnsubscribeURL(user.ID),
+	}
+	if d.UserName == "" {
+		d.UserName = "there"
+	}
+	if prefs.DigestFrequency == models.DigestWeekly {
+		d.SinceLabel = "this week"
+	}
+
+	going := `(SELECT COUNT(*) FROM rsvps r WHERE r.event_id = e.id AND r.status = 'going') as going`
+
+	if err := database.DB.Raw(`
+		SELECT e.id, e.title, e.description, e.category, e.end_time, e.verified_count, `+going+`
+		FROM events e
+		WHERE `+filter+`
+		  AND e.start_time <= now() AND e.end_time > now()
+		ORDER BY e.start_time
+		LIMIT ?
+	`, append(filterArgs, maxUpcoming)...).Scan(&d.Upcoming).Error; err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.Raw(`
+		SELECT * FROM (
+			SELECT e.id, e.title, e.description, e.category, e.end_time, e.verified_count, `+going+`
+			FROM events e
+			WHERE `+filter+`
+			  AND e.start_time >= ?
+		) popular
+		WHERE verified_count > 0 OR going > 0
+		ORDER BY verified_count + going DESC, id DESC
+		LIMIT ?
+	`, append(filterArgs, since, maxPopular)...).Scan(&d.Popular).Error; err != nil {
+		return nil, err
+	}
+
+	for _, items := range [][]Item{d.Upcoming, d.Popular} {
+		for i := range items {
+			items[i].URL = fmt.Sprintf("%s/?event=%d", frontendURL(), items[i].ID)
+			items[i].Until = items[i].EndTime.In(config.Toronto()).Format("3:04 PM")
+		}
+	}
+	return d, nil
+}
+
+// Render turns a digest into an email with HTML and plain-text bodies and a
+// one-click unsubscribe header.
+func Render(d *Digest) (mail.Message, error) {
+	var html, text bytes.Buffer
+	if err := htmlTemplate.Execute(&html, d); err != nil {
+		return mail.Message{}, err
+	}
+	if err := textTemplate.Execute(&text, d); err != nil {
+		return mail.Message{}, err
+	}
+
+	return mail.Message{
+		To:      d.To,
+		Subject: fmt.Sprintf("UniSpot %s: what's on at %s", d.PeriodLabel, d.CampusName),
+		HTML:    html.String(),
+		Text:    text.String(),
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + d.UnsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	}, nil
+}
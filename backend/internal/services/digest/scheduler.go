@@ -0,0 +1,114 @@
+package digest
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/parsaabbasian/unispot/backend/internal/config"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/mail"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+)
+
+var periods = map[string]time.Duration{
+	models.DigestDaily:  24 * time.Hour,
+	models.DigestWeekly: 7 * 24 * time.Hour,
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+// slot returns the most recent send time at or before now: DIGEST_HOUR
+// (Toronto time) every day, or on DIGEST_WEEKDAY for weekly digests.
+func slot(frequency string, now time.Time) time.Time {
+	loc := config.Toronto()
+	local := now.In(loc)
+	s := time.Date(local.Year(), local.Month(), local.Day(), config.Int("DIGEST_HOUR", 8), 0, 0, 0, loc)
+
+	if frequency == models.DigestWeekly {
+		weekday, ok := weekdays[strings.ToLower(config.String("DIGEST_WEEKDAY", "monday"))]
+		if !ok {
+			weekday = time.Monday
+		}
+		s = s.AddDate(0, 0, -((int(s.Weekday()) - int(weekday) + 7) % 7))
+		if s.After(local) {
+			s = s.AddDate(0, 0, -7)
+		}
+		return s
+	}
+
+	if s.After(local) {
+		s = s.AddDate(0, 0, -1)
+	}
+	return s
+}
+
+// StartScheduler sends digests as they come due. It never returns.
+func StartScheduler(mailer mail.Mailer) {
+	ticker := time.NewTicker(5 * time.Minute)
+	log.Printf("Digest Scheduler started...")
+
+	for range ticker.C {
+		RunDue(mailer, time.Now())
+	}
+}
+
+// RunDue sends every digest whose slot has passed since the user's last one.
+// Each user is claimed before sending, so running several instances won't
+// send duplicates.
+func RunDue(mailer mail.Mailer, now time.Time) {
+	for frequency, period := range periods {
+		due := slot(frequency, now)
+
+		var rows []struct {
+			models.UserPreference
+			Email string
+			Name  string
+		}
+		if err := database.DB.Raw(`
+			SELECT p.*, u.email, u.name
+			FROM user_preferences p
+			JOIN users u ON u.id = p.user_id
+			WHERE p.digest_frequency = ? AND (p.last_digest_at IS NULL OR p.last_digest_at < ?)
+		`, frequency, due).Scan(&rows).Error; err != nil {
+			log.Printf("Failed to load %s digest recipients: %v", frequency, err)
+			continue
+		}
+
+		for _, row := range rows {
+			claim := database.DB.Model(&models.UserPreference{}).
+				Where("user_id = ? AND (last_digest_at IS NULL OR last_digest_at < ?)", row.UserID, due).
+				UpdateColumn("last_digest_at", now)
+			if claim.Error != nil || claim.RowsAffected == 0 {
+				continue
+			}
+
+			since := due.Add(-period)
+			if row.LastDigestAt != nil && row.LastDigestAt.After(since) {
+				since = *row.LastDigestAt
+			}
+			if err := send(mailer, models.User{ID: row.UserID, Email: row.Email, Name: row.Name}, row.UserPreference, since); err != nil {
+				log.Printf("Failed to send %s digest to user %d: %v", frequency, row.UserID, err)
+			}
+		}
+	}
+}
+
+func send(mailer mail.Mailer, user models.User, prefs models.UserPreference, since time.Time) error {
+	d, err := Build(user, prefs, since)
+	if err != nil {
+		return err
+	}
+	if d.Empty() {
+		return nil
+	}
+
+	msg, err := Render(d)
+	if err != nil {
+		return err
+	}
+	return mailer.Send(msg)
+}
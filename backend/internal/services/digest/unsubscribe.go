@@ -0,0 +1,28 @@
+package digest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/parsaabbasian/unispot/backend/internal/config"
+)
+
+func sign(userID uint) string {
+	mac := hmac.New(sha256.New, config.Secret("DIGEST_SECRET"))
+	fmt.Fprintf(mac, "unsubscribe:%d", userID)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// UnsubscribeURL is the signed link that turns a user's digests off.
+func UnsubscribeURL(userID uint) string {
+	base := strings.TrimRight(config.String("PUBLIC_API_URL", "http://localhost:8081"), "/")
+	return fmt.Sprintf("%s/api/digest/unsubscribe?u=%d&t=%s", base, userID, sign(userID))
+}
+
+// ValidUnsubscribe reports whether token was issued for userID.
+func ValidUnsubscribe(userID uint, token string) bool {
+	return hmac.Equal([]byte(sign(userID)), []byte(token))
+}
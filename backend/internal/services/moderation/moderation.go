@@ -0,0 +1,133 @@
+// Package moderation owns an event's review state. Every change goes through
+// Transition so the audit log, the derived is_approved/is_hidden columns and
+// connected clients stay in step.
+package moderation
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"github.com/parsaabbasian/unispot/backend/internal/services/push"
+	"github.com/parsaabbasian/unispot/backend/internal/services/reputation"
+	"github.com/parsaabbasian/unispot/backend/internal/ws"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Moderation states. Only approved events are shown on the map.
+const (
+	Pending  = "pending"
+	Approved = "approved"
+	Rejected = "rejected"
+	Hidden   = "hidden"
+)
+
+var (
+	ErrEventNotFound  = errors.New("Event not found")
+	ErrInvalidStatus  = errors.New("status must be one of pending, approved, rejected, hidden")
+	ErrReasonRequired = errors.New("A reason is required to reject or hide an event")
+)
+
+// ValidStatus reports whether s is a known moderation state.
+func ValidStatus(s string) bool {
+	switch s {
+	case Pending, Approved, Rejected, Hidden:
+		return true
+	}
+	return false
+}
+
+// Transition moves the event to status, recording reason and actor in the
+// moderation log, and broadcasts the change. Moving to the current status is a
+// no-op.
+func Transition(eventID uint, status, reason, actor string) (*models.Event, error) {
+	if !ValidStatus(status) {
+		return nil, ErrInvalidStatus
+	}
+	reason = strings.TrimSpace(reason)
+	if (status == Rejected || status == Hidden) && reason == "" {
+		return nil, ErrReasonRequired
+	}
+
+	var event models.Event
+	var from string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, eventID).Error; err != nil {
+			return ErrEventNotFound
+		}
+		from = event.ModerationStatus
+		if from == status {
+			return nil
+		}
+
+		event.ModerationStatus = status
+		event.ModerationReason = reason
+		event.IsApproved = status == Approved
+		event.IsHidden = status == Hidden
+		if err := tx.Model(&event).Updates(map[string]interface{}{
+			"moderation_status": event.ModerationStatus,
+			"moderation_reason": event.ModerationReason,
+			"is_approved":       event.IsApproved,
+			"is_hidden":         event.IsHidden,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.ModerationLog{
+			EventID:    event.ID,
+			FromStatus: from,
+			ToStatus:   status,
+			Reason:     reason,
+			Actor:      actor,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if from == status {
+		return &event, nil
+	}
+
+	var loc struct {
+		Lat float64
+		Lng float64
+	}
+	database.DB.Raw(`SELECT ST_Y(location::geometry) as lat, ST_X(location::geometry) as lng FROM events WHERE id = ?`, event.ID).Scan(&loc)
+	event.Latitude = loc.Lat
+	event.Longitude = loc.Lng
+
+	switch {
+	case status == Rejected:
+		reputation.Record(event.CreatorEmail, reputation.Rejected)
+	case status == Approved && from == Pending:
+		reputation.Record(event.CreatorEmail, reputation.Approved)
+	}
+
+	// Alerts go out when an event first reaches the map
+	if status == Approved && event.ArchivedAt == nil {
+		push.Enqueue(event.ID)
+	}
+
+	broadcast(&event, from)
+	return &event, nil
+}
+
+func broadcast(event *models.Event, from string) {
+	ws.GlobalHub.BroadcastEvent("moderation_update", map[string]interface{}{
+		"id":     event.ID,
+		"status": event.ModerationStatus,
+		"reason": event.ModerationReason,
+	})
+
+	switch {
+	case event.ModerationStatus == Approved:
+		ws.GlobalHub.BroadcastTopic(ws.EventTopic(event), "new_event", event)
+	case from == Approved:
+		ws.GlobalHub.BroadcastEvent("hide_event", map[string]interface{}{
+			"id":     event.ID,
+			"reason": event.ModerationStatus,
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Package push delivers Web Push alerts. When an event is published the
+// worker matches it against users' alert rules with PostGIS and queues one
+// notification per subscription, then sends them, retrying with backoff and
+// retiring subscriptions the push service reports gone.
+package push
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/SherClockHolmes/webpush-go"
+	"github.com/parsaabbasian/unispot/backend/internal/config"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+)
+
+// Sender delivers an encrypted payload to a subscription and returns the push
+// service's HTTP status.
+type Sender interface {
+	Send(sub *models.PushSubscription, payload []byte, ttl time.Duration, topic string) (int, error)
+}
+
+// WebPush sends through the subscription's push service, signed with the
+// server's VAPID key.
+type WebPush struct {
+	Client *http.Client
+}
+
+func (w WebPush) Send(sub *models.PushSubscription, payload []byte, ttl time.Duration, topic string) (int, error) {
+	public, private := VAPIDKeys()
+	resp, err := webpush.SendNotification(payload, &webpush.Subscription{
+		Endpoint: sub.Endpoint,
+		Keys:     webpush.Keys{P256dh: sub.P256dh, Auth: sub.Auth},
+	}, &webpush.Options{
+		HTTPClient:      w.Client,
+		Subscriber:      config.String("VAPID_SUBJECT", "admin@unispot.ca"),
+		VAPIDPublicKey:  public,
+		VAPIDPrivateKey: private,
+		TTL:             int(ttl.Seconds()),
+		Topic:           topic,
+		Urgency:         webpush.UrgencyHigh,
+	})
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// DefaultSender is what the worker sends with.
+var DefaultSender Sender = WebPush{Client: &http.Client{Timeout: 15 * time.Second}}
+
+var (
+	vapidOnce    sync.Once
+	vapidPublic  string
+	vapidPrivate string
+)
+
+// VAPIDKeys returns the server's VAPID key pair. When VAPID_PUBLIC_KEY and
+// VAPID_PRIVATE_KEY are unset a pair is generated for the life of the
+// process, so subscriptions won't survive a restart.
+func VAPIDKeys() (public, private string) {
+	vapidOnce.Do(func() {
+		vapidPublic = config.String("VAPID_PUBLIC_KEY", "")
+		vapidPrivate = config.String("VAPID_PRIVATE_KEY", "")
+		if vapidPublic != "" && vapidPrivate != "" {
+			return
+		}
+		log.Printf("WARNING: VAPID keys not set, using a random per-process pair")
+		var err error
+		if vapidPrivate, vapidPublic, err = webpush.GenerateVAPIDKeys(); err != nil {
+			log.Printf("Failed to generate VAPID keys: %v", err)
+		}
+	})
+	return vapidPublic, vapidPrivate
+}
+
+// AllowHTTPEndpoints reports whether plain-http push endpoints are accepted,
+// which only makes sense when testing against cmd/fakepush.
+func AllowHTTPEndpoints() bool {
+	return config.String("PUSH_ALLOW_HTTP", "") == "true"
+}
+
+// published carries newly published event IDs to the worker for matching.
+var published = make(chan uint, 256)
+
+// Enqueue hands a newly published event to the worker, which matches it
+// against alert rules and queues the pushes. It never blocks the caller.
+func Enqueue(eventID uint) {
+	select {
+	case published <- eventID:
+	default:
+		go match(eventID)
+	}
+}
+
+// match queues a push for every live subscription whose owner has an alert
+// rule matching the event. An event is only ever queued once per
+// subscription, so matching again (say, on re-approval) sends nothing new.
+// Creators aren't alerted about their own events.
+func match(eventID uint) {
+	result := database.DB.Exec(`
+		WITH local AS (
+			SELECT (EXTRACT(HOUR FROM now() AT TIME ZONE 'America/Toronto') * 60
+				+ EXTRACT(MINUTE FROM now() AT TIME ZONE 'America/Toronto'))::int AS minute
+		)
+		INSERT INTO push_notifications (event_id, subscription_id, rule_id, status, attempts, next_attempt_at, created_at, updated_at)
+		SELECT DISTINCT ON (s.id) e.id, s.id, r.id, ?, 0, now(), now(), now()
+		FROM events e
+		CROSS JOIN local
+		JOIN alert_rules r ON (r.category = '' OR r.category = e.category)
+			AND ST_DWithin(e.location, r.location, r.radius_m)
+		JOIN users u ON u.id = r.user_id
+		JOIN push_subscriptions s ON s.user_id = r.user_id AND s.expired_at IS NULL
+		WHERE e.id = ?
+		  AND e.is_approved = true AND e.is_hidden = false AND e.archived_at IS NULL
+		  AND LOWER(COALESCE(e.creator_email, '')) <> u.email
+		  AND (r.start_minute IS NULL OR r.end_minute IS NULL
+			OR (r.start_minute <= r.end_minute AND local.minute >= r.start_minute AND local.minute < r.end_minute)
+			OR (r.start_minute > r.end_minute AND (local.minute >= r.start_minute OR local.minute < r.end_minute)))
+		ORDER BY s.id, r.id
+		ON CONFLICT (event_id, subscription_id) DO NOTHING
+	`, models.PushPending, eventID)
+	if result.Error != nil {
+		log.Printf("Failed to queue alerts for event %d: %v", eventID, result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Queued %d alerts for event %d", result.RowsAffected, eventID)
+		wake()
+	}
+}
+
+// topic collapses repeat pushes about the same event in the push service.
+func topic(eventID uint) string {
+	return fmt.Sprintf("event-%d", eventID)
+}
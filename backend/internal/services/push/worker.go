@@ -0,0 +1,185 @@
+package push
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/parsaabbasian/unispot/backend/internal/config"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+)
+
+const (
+	batchSize = 50
+
+	// A claimed notification is retried after this long if the worker dies mid-send.
+	claimTimeout = 2 * time.Minute
+)
+
+var wakeCh = make(chan struct{}, 1)
+
+// wake nudges the worker to look for due notifications now.
+func wake() {
+	select {
+	case wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// StartWorker matches published events to alert rules and delivers queued
+// notifications as they come due. It never returns.
+func StartWorker() {
+	ticker := time.NewTicker(10 * time.Second)
+	log.Printf("Push Worker started...")
+
+	for {
+		select {
+		case eventID := <-published:
+			match(eventID)
+		case <-ticker.C:
+		case <-wakeCh:
+		}
+		// Keep going while full batches come back
+		for {
+			if deliverDue() < batchSize {
+				break
+			}
+		}
+	}
+}
+
+type dueNotification struct {
+	models.PushNotification
+	Endpoint   string
+	P256dh     string
+	Auth       string
+	Title      string
+	Category   string
+	EndTime    time.Time
+	EventLive  bool
+	SubExpired bool
+}
+
+// deliverDue claims and sends one batch, returning how many it claimed.
+func deliverDue() int {
+	var due []dueNotification
+	err := database.DB.Raw(`
+		WITH claimed AS (
+			UPDATE push_notifications SET next_attempt_at = ?
+			WHERE id IN (
+				SELECT id FROM push_notifications
+				WHERE status = ? AND next_attempt_at <= now()
+				ORDER BY next_attempt_at
+				LIMIT ?
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *
+		)
+		SELECT c.*, s.endpoint, s.p256dh, s.auth, s.expired_at IS NOT NULL as sub_expired,
+			e.title, e.category, e.end_time,
+			(e.is_approved AND NOT e.is_hidden AND e.archived_at IS NULL AND e.end_time > now()) as event_live
+		FROM claimed c
+		JOIN push_subscriptions s ON s.id = c.subscription_id
+		JOIN events e ON e.id = c.event_id
+	`, time.Now().Add(claimTimeout), models.PushPending, batchSize).Scan(&due).Error
+	if err != nil {
+		log.Printf("Failed to claim push notifications: %v", err)
+		return 0
+	}
+
+	for i := range due {
+		deliver(&due[i])
+	}
+	return len(due)
+}
+
+func deliver(n *dueNotification) {
+	switch {
+	case n.SubExpired:
+		finish(n, models.PushExpired, "subscription expired")
+		return
+	case !n.EventLive:
+		finish(n, models.PushFailed, "event is no longer live")
+		return
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"title":    n.Title,
+		"body":     fmt.Sprintf("New %s event near you", strings.ToLower(n.Category)),
+		"event_id": n.EventID,
+		"url":      fmt.Sprintf("%s/?event=%d", strings.TrimRight(config.String("FRONTEND_URL", "https://yorkunispot.vercel.app"), "/"), n.EventID),
+	})
+
+	// No point delivering after the event is over
+	ttl := time.Until(n.EndTime)
+	if ttl < time.Minute {
+		ttl = time.Minute
+	}
+
+	sub := &models.PushSubscription{ID: n.SubscriptionID, Endpoint: n.Endpoint, P256dh: n.P256dh, Auth: n.Auth}
+	status, err := DefaultSender.Send(sub, payload, ttl, topic(n.EventID))
+
+	switch {
+	case err == nil && status >= 200 && status < 300:
+		finish(n, models.PushSent, "")
+	case status == http.StatusNotFound || status == http.StatusGone:
+		expireSubscription(n.SubscriptionID)
+		finish(n, models.PushExpired, fmt.Sprintf("push service returned %d", status))
+	case status == http.StatusBadRequest || status == http.StatusRequestEntityTooLarge:
+		finish(n, models.PushFailed, fmt.Sprintf("push service returned %d", status))
+	default:
+		reason := fmt.Sprintf("push service returned %d", status)
+		if err != nil {
+			reason = err.Error()
+		}
+		retry(n, reason)
+	}
+}
+
+func finish(n *dueNotification, status, reason string) {
+	if err := database.DB.Model(&models.PushNotification{}).Where("id = ?", n.ID).Updates(map[string]interface{}{
+		"status":     status,
+		"attempts":   n.Attempts + 1,
+		"last_error": reason,
+	}).Error; err != nil {
+		log.Printf("Failed to update push notification %d: %v", n.ID, err)
+	}
+}
+
+// retry schedules another attempt with exponential backoff from
+// PUSH_RETRY_BASE, giving up after PUSH_MAX_ATTEMPTS.
+func retry(n *dueNotification, reason string) {
+	attempts := n.Attempts + 1
+	if attempts >= config.Int("PUSH_MAX_ATTEMPTS", 5) {
+		finish(n, models.PushFailed, reason)
+		return
+	}
+
+	backoff := config.Duration("PUSH_RETRY_BASE", 30*time.Second) * time.Duration(math.Pow(2, float64(attempts-1)))
+	if err := database.DB.Model(&models.PushNotification{}).Where("id = ?", n.ID).Updates(map[string]interface{}{
+		"attempts":        attempts,
+		"last_error":      reason,
+		"next_attempt_at": time.Now().Add(backoff),
+	}).Error; err != nil {
+		log.Printf("Failed to reschedule push notification %d: %v", n.ID, err)
+	}
+}
+
+// expireSubscription retires a subscription the push service no longer knows
+// and drops anything still queued for it.
+func expireSubscription(id uint) {
+	now := time.Now()
+	if err := database.DB.Model(&models.PushSubscription{}).Where("id = ? AND expired_at IS NULL", id).Update("expired_at", now).Error; err != nil {
+		log.Printf("Failed to expire push subscription %d: %v", id, err)
+	}
+	if err := database.DB.Model(&models.PushNotification{}).
+		Where("subscription_id = ? AND status = ?", id, models.PushPending).
+		Updates(map[string]interface{}{"status": models.PushExpired, "last_error": "subscription expired"}).Error; err != nil {
+		log.Printf("Failed to drop queued pushes for subscription %d: %v", id, err)
+	}
+}
@@ -0,0 +1,93 @@
+// Package recommendation ranks nearby events for a user by category-based
+// boosting: categories the user has RSVPed to, verified or looked at score
+// higher, and every event's score falls off with distance.
+//
+// score = (base + category match) / (1 + distance in km)
+package recommendation
+
+import (
+	"time"
+
+	"github.com/parsaabbasian/unispot/backend/internal/config"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+)
+
+// Only a user's strongest few categories are boosted.
+const topCategories = 5
+
+// Weights tune the scoring. Each field is configurable; see LoadWeights.
+type Weights struct {
+	Base         float64       // score every event starts with
+	RSVP         float64       // preference added per RSVP in a category
+	Verification float64       // ... per verification
+	View         float64       // ... per event view
+	MaxMatch     float64       // cap on a single category's boost
+	History      time.Duration // how far back activity counts
+}
+
+// LoadWeights reads the RECOMMEND_* settings.
+func LoadWeights() Weights {
+	return Weights{
+		Base:         config.Float("RECOMMEND_BASE", 1.0),
+		RSVP:         config.Float("RECOMMEND_WEIGHT_RSVP", 0.1),
+		Verification: config.Float("RECOMMEND_WEIGHT_VERIFICATION", 0.08),
+		View:         config.Float("RECOMMEND_WEIGHT_VIEW", 0.02),
+		MaxMatch:     config.Float("RECOMMEND_MAX_MATCH", 1.0),
+		History:      config.Duration("RECOMMEND_HISTORY", 90*24*time.Hour),
+	}
+}
+
+// Preferences returns the user's boosted categories and how much each is
+// boosted by, built from their recent RSVPs, verifications and views.
+func Preferences(user *models.User, w Weights) (map[string]float64, error) {
+	prefs := make(map[string]float64)
+	if user == nil {
+		return prefs, nil
+	}
+
+	var results []struct {
+		Category string
+		Weight   float64
+	}
+	since := time.Now().Add(-w.History)
+	err := database.DB.Raw(`
+		SELECT e.category, SUM(a.weight) as weight
+		FROM (
+			SELECT event_id, ?::float8 as weight FROM rsvps WHERE user_id = ? AND created_at >= ?
+			UNION ALL
+			SELECT event_id, ?::float8 FROM verifications WHERE LOWER(user_email) = ? AND created_at >= ?
+			UNION ALL
+			SELECT event_id, ?::float8 FROM event_views WHERE user_id = ? AND created_at >= ?
+		) a
+		JOIN events e ON e.id = a.event_id
+		GROUP BY e.category
+		ORDER BY weight DESC
+		LIMIT ?
+	`,
+		w.RSVP, user.ID, since,
+		w.Verification, user.Email, since,
+		w.View, user.ID, since,
+		topCategories,
+	).Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range results {
+		if r.Weight > w.MaxMatch {
+			r.Weight = w.MaxMatch
+		}
+		if r.Weight > 0 {
+			prefs[r.Category] = r.Weight
+		}
+	}
+	return prefs, nil
+}
+
+// Score rates one event for a user, returning the score and the category
+// boost that went into it.
+func Score(prefs map[string]float64, category string, distanceKm float64, w Weights) (float64, float64) {
+	match := prefs[category]
+	return (w.Base + match) / (1 + distanceKm), match
+}
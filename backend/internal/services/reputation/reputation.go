@@ -0,0 +1,109 @@
+// Package reputation keeps a running score per event creator, built from how
+// the community and admins have received their events, and turns it into a
+// publishing decision for their next submission.
+package reputation
+
+import (
+	"log"
+	"strings"
+
+	"github.com/parsaabbasian/unispot/backend/internal/config"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+)
+
+// Signal is something that happened to one of a creator's events.
+type Signal int
+
+const (
+	VerificationReceived Signal = iota
+	DisputeReceived
+	Approved // an admin approved a held event
+	Rejected // an admin rejected an event
+)
+
+// weight is how much a signal moves the score. Each is configurable.
+func (s Signal) weight() float64 {
+	switch s {
+	case VerificationReceived:
+		return config.Float("REPUTATION_WEIGHT_VERIFICATION", 1)
+	case DisputeReceived:
+		return config.Float("REPUTATION_WEIGHT_DISPUTE", -2)
+	case Approved:
+		return config.Float("REPUTATION_WEIGHT_APPROVAL", 3)
+	case Rejected:
+		return config.Float("REPUTATION_WEIGHT_REJECTION", -10)
+	}
+	return 0
+}
+
+func normalize(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Record applies a signal to the creator's stored score. Anonymous creators
+// (no email) have no reputation to update.
+func Record(email string, s Signal) {
+	email = normalize(email)
+	if email == "" {
+		return
+	}
+
+	counts := map[Signal]string{
+		VerificationReceived: "verifications",
+		DisputeReceived:      "disputes",
+		Approved:             "approvals",
+		Rejected:             "rejections",
+	}
+	column := counts[s]
+
+	err := database.DB.Exec(`
+		INSERT INTO creator_reputations (email, score, `+column+`, updated_at)
+		VALUES (?, ?, 1, now())
+		ON CONFLICT (email) DO UPDATE SET
+			score = creator_reputations.score + EXCLUDED.score,
+			`+column+` = creator_reputations.`+column+` + 1,
+			updated_at = now()
+	`, email, s.weight()).Error
+	if err != nil {
+		log.Printf("Failed to record reputation signal for %s: %v", email, err)
+	}
+}
+
+// Score returns the creator's current score; creators without history score 0.
+func Score(email string) float64 {
+	email = normalize(email)
+	if email == "" {
+		return 0
+	}
+	var rep models.CreatorReputation
+	if err := database.DB.First(&rep, "email = ?", email).Error; err != nil {
+		return 0
+	}
+	return rep.Score
+}
+
+// Tier is how a creator's next submission is treated.
+type Tier string
+
+const (
+	Trusted   Tier = "trusted"   // published immediately, even from automated sources
+	Standard  Tier = "standard"  // published immediately
+	Probation Tier = "probation" // published, under a tighter rate limit
+	Held      Tier = "held"      // waits in the moderation queue
+)
+
+// TierFor maps a score onto a tier using the REPUTATION_TRUSTED,
+// REPUTATION_PROBATION and REPUTATION_HOLD thresholds. Anonymous creators
+// can't earn reputation, so they start on probation.
+func TierFor(email string, score float64) Tier {
+	switch {
+	case score <= config.Float("REPUTATION_HOLD", -10):
+		return Held
+	case normalize(email) == "" || score < config.Float("REPUTATION_PROBATION", 0):
+		return Probation
+	case score >= config.Float("REPUTATION_TRUSTED", 10):
+		return Trusted
+	}
+	return Standard
+}
@@ -0,0 +1,149 @@
+package safety
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/parsaabbasian/unispot/backend/internal/config"
+)
+
+// BannedWords rejects submissions containing any word or phrase from the
+// comma-separated SAFETY_BANNED_WORDS list, matched case-insensitively on
+// word boundaries.
+type BannedWords struct {
+	once    sync.Once
+	pattern *regexp.Regexp
+}
+
+// load compiles the list on first use, after .env has been loaded.
+func (b *BannedWords) load() {
+	var words []string
+	for _, w := range strings.Split(config.String("SAFETY_BANNED_WORDS", ""), ",") {
+		if w = strings.TrimSpace(w); w != "" {
+			words = append(words, regexp.QuoteMeta(strings.ToLower(w)))
+		}
+	}
+	if len(words) > 0 {
+		b.pattern = regexp.MustCompile(`\b(` + strings.Join(words, "|") + `)\b`)
+	}
+}
+
+func (b *BannedWords) Check(s Submission) Result {
+	b.once.Do(b.load)
+	if b.pattern == nil {
+		return allow()
+	}
+	if match := b.pattern.FindString(strings.ToLower(s.Text())); match != "" {
+		return Result{Verdict: Reject, Reasons: []string{fmt.Sprintf("contains a banned word (%q)", match)}}
+	}
+	return allow()
+}
+
+var (
+	linkPattern      = regexp.MustCompile(`(?i)\b(https?://|www\.)\S+|\b[a-z0-9-]+\.(com|net|org|io|ly|co|xyz|info|biz|gg)\b(/\S*)?`)
+	shortenerPattern = regexp.MustCompile(`(?i)\b(bit\.ly|tinyurl\.com|t\.co|goo\.gl|ow\.ly|is\.gd|cutt\.ly|rb\.gy)\b`)
+	phonePattern     = regexp.MustCompile(`(\+?1[\s.-]?)?\(?\d{3}\)?[\s.-]?\d{3}[\s.-]?\d{4}\b`)
+)
+
+// LinkSpam holds submissions with too many links, links in the title, or URL shorteners.
+type LinkSpam struct{}
+
+func (LinkSpam) Check(s Submission) Result {
+	var reasons []string
+	if links := linkPattern.FindAllString(s.Text(), -1); len(links) > config.Int("SAFETY_MAX_LINKS", 2) {
+		reasons = append(reasons, fmt.Sprintf("contains %d links", len(links)))
+	}
+	if linkPattern.MatchString(s.Title) {
+		reasons = append(reasons, "title contains a link")
+	}
+	if shortenerPattern.MatchString(s.Text()) {
+		reasons = append(reasons, "uses a link shortener")
+	}
+	if len(reasons) > 0 {
+		return Result{Verdict: Hold, Reasons: reasons}
+	}
+	return allow()
+}
+
+// PhoneSpam holds submissions that advertise phone numbers.
+type PhoneSpam struct{}
+
+func (PhoneSpam) Check(s Submission) Result {
+	if phonePattern.MatchString(s.Title) {
+		return Result{Verdict: Hold, Reasons: []string{"title contains a phone number"}}
+	}
+	if n := len(phonePattern.FindAllString(s.Description, -1)); n > 1 {
+		return Result{Verdict: Hold, Reasons: []string{fmt.Sprintf("contains %d phone numbers", n)}}
+	}
+	return allow()
+}
+
+// AllCaps holds shouty submissions where most letters are upper case.
+type AllCaps struct{}
+
+// Short strings like "FREE PIZZA" are fine; only judge text with this many letters.
+const allCapsMinLetters = 12
+
+func (AllCaps) Check(s Submission) Result {
+	for _, field := range []struct{ name, text string }{{"title", s.Title}, {"description", s.Description}} {
+		letters, upper := 0, 0
+		for _, r := range field.text {
+			if unicode.IsLetter(r) {
+				letters++
+				if unicode.IsUpper(r) {
+					upper++
+				}
+			}
+		}
+		if letters >= allCapsMinLetters && float64(upper)/float64(letters) > 0.8 {
+			return Result{Verdict: Hold, Reasons: []string{field.name + " is written in all caps"}}
+		}
+	}
+	return allow()
+}
+
+// Repetition holds submissions padded with repeated characters ("!!!!!!!")
+// or the same word over and over.
+type Repetition struct{}
+
+const (
+	maxRepeatedChars = 5
+	maxRepeatedWords = 3
+)
+
+func (Repetition) Check(s Submission) Result {
+	text := s.Text()
+
+	var prev rune
+	run := 0
+	for _, r := range text {
+		if r == prev && !unicode.IsSpace(r) {
+			run++
+		} else {
+			run = 1
+		}
+		prev = r
+		if run > maxRepeatedChars {
+			return Result{Verdict: Hold, Reasons: []string{"contains long runs of repeated characters"}}
+		}
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	run = 1
+	for i := 1; i < len(words); i++ {
+		if words[i] == words[i-1] {
+			run++
+		} else {
+			run = 1
+		}
+		if run > maxRepeatedWords {
+			return Result{Verdict: Hold, Reasons: []string{fmt.Sprintf("repeats %q over and over", words[i])}}
+		}
+	}
+	return allow()
+}
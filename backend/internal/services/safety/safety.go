@@ -0,0 +1,111 @@
+// Package safety screens user-submitted text before it reaches the map.
+// A Pipeline runs every registered Check and keeps the most severe verdict.
+package safety
+
+import (
+	"sync"
+)
+
+// Verdict is a check's decision, ordered from least to most severe.
+type Verdict int
+
+const (
+	Allow  Verdict = iota
+	Hold           // publish only after an admin reviews it
+	Reject         // refuse the submission outright
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Hold:
+		return "hold"
+	case Reject:
+		return "reject"
+	}
+	return "allow"
+}
+
+func (v Verdict) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+// Submission is the user-written text being screened.
+type Submission struct {
+	Title       string
+	Description string
+}
+
+// Text returns all screened fields joined together.
+func (s Submission) Text() string {
+	return s.Title + "\n" + s.Description
+}
+
+// Result is a verdict plus the human-readable reasons behind it.
+type Result struct {
+	Verdict Verdict  `json:"verdict"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+func allow() Result { return Result{Verdict: Allow} }
+
+// Check is a single content rule. Implement it to add new rules and pass
+// them to Register.
+type Check interface {
+	Check(s Submission) Result
+}
+
+// CheckFunc adapts a plain function to the Check interface.
+type CheckFunc func(s Submission) Result
+
+func (f CheckFunc) Check(s Submission) Result { return f(s) }
+
+type Pipeline struct {
+	mu     sync.RWMutex
+	checks []Check
+}
+
+func NewPipeline(checks ...Check) *Pipeline {
+	return &Pipeline{checks: checks}
+}
+
+// Register appends a check to the pipeline.
+func (p *Pipeline) Register(c Check) {
+	p.mu.Lock()
+	p.checks = append(p.checks, c)
+	p.mu.Unlock()
+}
+
+// Run screens s with every check, returning the most severe verdict and the
+// reasons from all checks that didn't allow it.
+func (p *Pipeline) Run(s Submission) Result {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	result := allow()
+	for _, c := range p.checks {
+		r := c.Check(s)
+		if r.Verdict == Allow {
+			continue
+		}
+		if r.Verdict > result.Verdict {
+			result.Verdict = r.Verdict
+		}
+		result.Reasons = append(result.Reasons, r.Reasons...)
+	}
+	return result
+}
+
+// Default is the pipeline used for event and comment submissions.
+var Default = NewPipeline(
+	&BannedWords{},
+	LinkSpam{},
+	PhoneSpam{},
+	AllCaps{},
+	Repetition{},
+)
+
+// Register adds a check to the Default pipeline.
+func Register(c Check) { Default.Register(c) }
+
+// Run screens s with the Default pipeline.
+func Run(s Submission) Result { return Default.Run(s) }
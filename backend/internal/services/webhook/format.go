@@ -0,0 +1,340 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/parsaabbasian/unispot/backend/internal/config"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// categoryColors match the map pins in the frontend.
+var categoryColors = map[string]int{
+	"Food":   0xF59E0B,
+	"Study":  0x10B981,
+	"Social": 0xEC4899,
+	"Tech":   0x6366F1,
+	"Music":  0x8B5CF6,
+	"Sports": 0xEF4444,
+	"Safety": 0xF43F5E,
+	"Sale":   0xF97316,
+}
+
+const defaultColor = 0x6366F1
+
+// card is what a chat message shows. It's loaded when the message is sent,
+// so a late delivery still shows the current verification count, and isn't
+// sent at all once the event is off the public map.
+type card struct {
+	ID            uint
+	Title         string
+	Description   string
+	Category      string
+	VerifiedCount int
+	EndTime       time.Time
+}
+
+func loadCard(eventID uint) (*card, error) {
+	var c card
+	if err := database.DB.Raw(`
+		SELECT id, title, description, category, verified_count, end_time
+		FROM events
+		WHERE id = ? AND moderation_status = 'approved' AND is_hidden = false
+	`, eventID).Scan(&c).Error; err != nil {
+		return nil, err
+	}
+	if c.ID == 0 {
+		return nil, permanentError("event is gone or no longer public")
+	}
+	return &c, nil
+}
+
+func (c *card) mapURL() string {
+	return fmt.Sprintf("%s/?event=%d", strings.TrimRight(config.String("FRONTEND_URL", "https://yorkunispot.vercel.app"), "/"), c.ID)
+}
+
+func (c *card) color() int {
+	if color, ok := categoryColors[c.Category]; ok {
+		return color
+	}
+	return defaultColor
+}
+
+func (c *card) verified() string {
+	switch c.VerifiedCount {
+	case 0:
+		return "Not verified yet"
+	case 1:
+		return "✅ 1 student"
+	default:
+		return fmt.Sprintf("✅ %d students", c.VerifiedCount)
+	}
+}
+
+func truncate(s string, n int) string {
+	r := []rune(strings.TrimSpace(s))
+	if len(r) <= n {
+		return string(r)
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// findMessage returns the message already posted for the event, if any.
+func findMessage(subscriptionID, eventID uint) (*models.WebhookMessage, error) {
+	var msg models.WebhookMessage
+	err := database.DB.Where("subscription_id = ? AND event_id = ?", subscriptionID, eventID).First(&msg).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func saveMessage(d *dueDelivery, messageID, channel string) error {
+	return database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "subscription_id"}, {Name: "event_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"message_id", "channel", "updated_at"}),
+	}).Create(&models.WebhookMessage{
+		SubscriptionID: d.SubscriptionID,
+		EventID:        d.EventID,
+		MessageID:      messageID,
+		Channel:        channel,
+	}).Error
+}
+
+// discordMessage is a webhook message with one embed.
+func discordMessage(c *card) map[string]interface{} {
+	embed := map[string]interface{}{
+		"title": truncate(c.Title, 256),
+		"url":   c.mapURL(),
+		"color": c.color(),
+		"fields": []map[string]interface{}{
+			{"name": "Category", "value": c.Category, "inline": true},
+			{"name": "Verified", "value": c.verified(), "inline": true},
+			{"name": "Until", "value": fmt.Sprintf("<t:%d:t>", c.EndTime.Unix()), "inline": true},
+			{"name": "Map", "value": fmt.Sprintf("[Open on UniSpot](%s)", c.mapURL())},
+		},
+		"footer": map[string]string{"text": "UniSpot · updates as students verify"},
+	}
+	if description := truncate(c.Description, 1000); description != "" {
+		embed["description"] = description
+	}
+	return map[string]interface{}{
+		"username":         "UniSpot",
+		"embeds":           []interface{}{embed},
+		"allowed_mentions": map[string]interface{}{"parse": []string{}},
+	}
+}
+
+// discordURL adds a path suffix and query to the subscription's webhook URL.
+func discordURL(base, suffix string, query url.Values) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", permanentError(err.Error())
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + suffix
+	q := u.Query()
+	for k, v := range query {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// sendDiscord posts an embed for the event through a Discord webhook, or
+// edits the one already posted.
+func sendDiscord(d *dueDelivery) (int, error) {
+	c, err := loadCard(d.EventID)
+	if err != nil {
+		return 0, err
+	}
+	body, _ := json.Marshal(discordMessage(c))
+
+	msg, err := findMessage(d.SubscriptionID, d.EventID)
+	if err != nil {
+		return 0, err
+	}
+	if msg != nil {
+		target, err := discordURL(d.URL, "/messages/"+msg.MessageID, nil)
+		if err != nil {
+			return 0, err
+		}
+		req, err := jsonRequest(http.MethodPatch, target, body)
+		if err != nil {
+			return 0, err
+		}
+		status, _, err := send(req)
+		if status != http.StatusNotFound {
+			return status, err
+		}
+		// The message was deleted in Discord; post a new one
+	}
+
+	target, err := discordURL(d.URL, "", url.Values{"wait": {"true"}})
+	if err != nil {
+		return 0, err
+	}
+	req, err := jsonRequest(http.MethodPost, target, body)
+	if err != nil {
+		return 0, err
+	}
+	status, resp, err := send(req)
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden || status == http.StatusNotFound:
+		return status, permanentError(err.Error())
+	case err != nil:
+		return status, err
+	}
+
+	var posted struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(resp, &posted); err != nil || posted.ID == "" {
+		return status, permanentError("Discord didn't return the message id")
+	}
+	return status, saveMessage(d, posted.ID, "")
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackMessage is a Block Kit message inside a coloured attachment, since
+// blocks alone can't carry the category colour.
+func slackMessage(c *card) map[string]interface{} {
+	text := fmt.Sprintf("*<%s|%s>*", c.mapURL(), slackEscaper.Replace(truncate(c.Title, 150)))
+	if description := truncate(c.Description, 500); description != "" {
+		text += "\n" + slackEscaper.Replace(description)
+	}
+	until := c.EndTime.In(config.Toronto()).Format(time.Kitchen)
+
+	return map[string]interface{}{
+		"text": slackEscaper.Replace(fmt.Sprintf("New %s event: %s", c.Category, c.Title)),
+		"attachments": []interface{}{map[string]interface{}{
+			"color": fmt.Sprintf("#%06X", c.color()),
+			"blocks": []interface{}{
+				map[string]interface{}{
+					"type": "section",
+					"text": map[string]string{"type": "mrkdwn", "text": text},
+				},
+				map[string]interface{}{
+					"type": "context",
+					"elements": []interface{}{
+						map[string]string{"type": "mrkdwn", "text": "*" + slackEscaper.Replace(c.Category) + "*"},
+						map[string]string{"type": "mrkdwn", "text": c.verified()},
+						map[string]string{"type": "mrkdwn", "text": fmt.Sprintf("Until <!date^%d^{time}|%s>", c.EndTime.Unix(), until)},
+					},
+				},
+				map[string]interface{}{
+					"type": "actions",
+					"elements": []interface{}{map[string]interface{}{
+						"type": "button",
+						"text": map[string]string{"type": "plain_text", "text": "View on map"},
+						"url":  c.mapURL(),
+					}},
+				},
+			},
+		}},
+	}
+}
+
+// Slack API errors worth retrying; any other error won't fix itself.
+var slackTransient = map[string]bool{
+	"ratelimited":         true,
+	"internal_error":      true,
+	"fatal_error":         true,
+	"service_unavailable": true,
+	"request_timeout":     true,
+}
+
+type slackResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// slackCall calls a Slack Web API method with the subscription's bot token.
+func slackCall(d *dueDelivery, method string, message map[string]interface{}) (int, *slackResponse, error) {
+	body, _ := json.Marshal(message)
+	req, err := jsonRequest(http.MethodPost, strings.TrimRight(d.URL, "/")+"/"+method, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+d.Token)
+
+	status, resp, err := send(req)
+	if err != nil {
+		return status, nil, err
+	}
+	var result slackResponse
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return status, nil, fmt.Errorf("bad Slack response: %w", err)
+	}
+	return status, &result, nil
+}
+
+// sendSlack posts the event to the subscription's channel with
+// chat.postMessage, or edits the message already posted with chat.update.
+func sendSlack(d *dueDelivery) (int, error) {
+	c, err := loadCard(d.EventID)
+	if err != nil {
+		return 0, err
+	}
+
+	msg, err := findMessage(d.SubscriptionID, d.EventID)
+	if err != nil {
+		return 0, err
+	}
+	if msg != nil {
+		message := slackMessage(c)
+		message["channel"] = msg.Channel
+		message["ts"] = msg.MessageID
+		status, resp, err := slackCall(d, "chat.update", message)
+		switch {
+		case err != nil:
+			return status, err
+		case resp.OK:
+			return status, nil
+		case resp.Error != "message_not_found":
+			return status, slackError(resp.Error)
+		}
+		// The message was deleted in Slack; post a new one
+	}
+
+	message := slackMessage(c)
+	message["channel"] = d.Channel
+	status, resp, err := slackCall(d, "chat.postMessage", message)
+	switch {
+	case err != nil:
+		return status, err
+	case !resp.OK:
+		return status, slackError(resp.Error)
+	}
+	return status, saveMessage(d, resp.TS, resp.Channel)
+}
+
+func slackError(code string) error {
+	if slackTransient[code] {
+		return errors.New("Slack returned " + code)
+	}
+	return permanentError("Slack returned " + code)
+}
+
+func jsonRequest(method, target string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, permanentError(err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	return req, nil
+}
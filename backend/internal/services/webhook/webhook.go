@@ -0,0 +1,172 @@
+// Package webhook forwards event lifecycle changes to admin-registered URLs.
+// Every hub broadcast that maps to a webhook type is queued once per matching
+// subscription, then POSTed as HMAC-SHA256-signed JSON, retrying with backoff
+// until it is delivered or dead-lettered.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+)
+
+// Webhook event types.
+const (
+	EventCreated  = "event.created" // reached the map: created pre-approved, approved or restored
+	EventUpdated  = "event.updated"
+	EventVerified = "event.verified"
+	EventApproved = "event.approved"
+	EventRejected = "event.rejected"
+	EventHidden   = "event.hidden"
+	EventMerged   = "event.merged"
+	EventDeleted  = "event.deleted"
+)
+
+// Types lists every event type a subscription can filter on.
+var Types = []string{EventCreated, EventUpdated, EventVerified, EventApproved, EventRejected, EventHidden, EventMerged, EventDeleted}
+
+// ValidType reports whether t is a known event type.
+func ValidType(t string) bool {
+	for _, known := range Types {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Hub broadcast actions and the types they're sent as.
+var actionTypes = map[string]string{
+	"new_event":    EventCreated,
+	"update_event": EventUpdated,
+	"verify_event": EventVerified,
+	"hide_event":   EventHidden,
+	"merge_event":  EventMerged,
+	"delete_event": EventDeleted,
+}
+
+// envelope is the body POSTed to subscribers.
+type envelope struct {
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+type occurrence struct {
+	eventType string
+	eventID   uint
+	category  string
+	payload   []byte
+}
+
+// occurred carries broadcasts to the worker for fan-out.
+var occurred = make(chan occurrence, 256)
+
+// Observe is a ws.Observer: it queues a webhook for each broadcast that
+// maps to an event type. It never blocks the broadcaster.
+func Observe(action string, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Webhook marshal error for %s: %v", action, err)
+		return
+	}
+
+	eventType := actionTypes[action]
+	if action == "moderation_update" {
+		var update struct {
+			Status string `json:"status"`
+		}
+		json.Unmarshal(raw, &update)
+		switch update.Status {
+		case "approved":
+			eventType = EventApproved
+		case "rejected":
+			eventType = EventRejected
+		}
+	}
+	if eventType == "" {
+		return
+	}
+
+	// Every lifecycle payload carries the event's id. Deletes carry the
+	// category too, since the row may be gone by the time it's fanned out.
+	var ref struct {
+		ID               uint   `json:"id"`
+		Category         string `json:"category"`
+		ModerationStatus string `json:"moderation_status"`
+		IsHidden         bool   `json:"is_hidden"`
+	}
+	json.Unmarshal(raw, &ref)
+
+	// The update trigger fires for every row, public or not
+	if eventType == EventUpdated && (ref.ModerationStatus != "approved" || ref.IsHidden) {
+		return
+	}
+
+	payload, err := json.Marshal(envelope{Type: eventType, OccurredAt: time.Now().UTC(), Data: raw})
+	if err != nil {
+		log.Printf("Webhook marshal error for %s: %v", action, err)
+		return
+	}
+
+	select {
+	case occurred <- occurrence{eventType: eventType, eventID: ref.ID, category: ref.Category, payload: payload}:
+	default:
+		go fanOut(eventType, ref.ID, ref.Category, payload)
+	}
+}
+
+// fanOut queues a delivery for every active subscription that wants
+// eventType for this event's category. Chat subscriptions only post new
+// events and edit the messages they've already posted when they're verified.
+func fanOut(eventType string, eventID uint, category string, payload []byte) {
+	result := database.DB.Exec(`
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at, updated_at)
+		SELECT s.id, ?, ?, ?::jsonb, ?, 0, now(), now(), now()
+		FROM webhook_subscriptions s
+		WHERE s.is_active = true
+		  AND (s.event_types IS NULL OR cardinality(s.event_types) = 0 OR ? = ANY(s.event_types))
+		  AND (s.category = '' OR s.category = COALESCE(NULLIF(?, ''), (SELECT category FROM events WHERE id = ?)))
+		  AND (s.format = ?
+			OR ? = ?
+			OR (? = ? AND EXISTS (SELECT 1 FROM webhook_messages m WHERE m.subscription_id = s.id AND m.event_id = ?)))
+	`, eventID, eventType, string(payload), models.WebhookPending,
+		eventType,
+		category, eventID,
+		models.WebhookJSON,
+		eventType, EventCreated,
+		eventType, EventVerified, eventID)
+	if result.Error != nil {
+		log.Printf("Failed to queue %s webhooks: %v", eventType, result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		wake()
+	}
+}
+
+// NewSecret returns a random signing secret for a subscription.
+func NewSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return "whsec_" + hex.EncodeToString(b)
+}
+
+// Sign returns the X-UniSpot-Signature value for body sent at timestamp:
+// "sha256=" and the hex HMAC-SHA256 of "<timestamp>.<body>" keyed by secret.
+// Receivers should recompute it and reject stale timestamps.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
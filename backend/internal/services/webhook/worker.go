@@ -0,0 +1,241 @@
+package webhook
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parsaabbasian/unispot/backend/internal/config"
+	"github.com/parsaabbasian/unispot/backend/internal/database"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
+)
+
+const (
+	batchSize = 20
+
+	// A claimed delivery is retried after this long if the worker dies mid-send.
+	claimTimeout = 2 * time.Minute
+
+	// How much of a failed response body is kept in the delivery log.
+	maxErrorBody = 512
+
+	// How much of any response body is read.
+	maxResponseBody = 64 << 10
+)
+
+// client is set up by StartWorker, once the environment is loaded.
+var client *http.Client
+
+var wakeCh = make(chan struct{}, 1)
+
+// wake nudges the worker to look for due deliveries now.
+func wake() {
+	select {
+	case wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// Redeliver puts a delivery back in the queue with a fresh set of attempts,
+// typically to retry a dead letter once the receiver is fixed.
+func Redeliver(id uint) error {
+	result := database.DB.Model(&models.WebhookDelivery{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":          models.WebhookPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+		"last_error":      "",
+	})
+	if result.Error == nil && result.RowsAffected > 0 {
+		wake()
+	}
+	return result.Error
+}
+
+// StartWorker fans broadcasts out to subscriptions and delivers queued
+// webhooks as they come due. It never returns.
+func StartWorker() {
+	client = &http.Client{Timeout: config.Duration("WEBHOOK_TIMEOUT", 10*time.Second)}
+	ticker := time.NewTicker(10 * time.Second)
+	log.Printf("Webhook Worker started...")
+
+	for {
+		select {
+		case o := <-occurred:
+			fanOut(o.eventType, o.eventID, o.category, o.payload)
+		case <-ticker.C:
+		case <-wakeCh:
+		}
+		// Keep going while full batches come back
+		for {
+			if deliverDue() < batchSize {
+				break
+			}
+		}
+	}
+}
+
+type dueDelivery struct {
+	models.WebhookDelivery
+	URL       string
+	Format    string
+	Channel   string
+	Secret    string
+	Token     string
+	SubActive bool
+}
+
+// deliverDue claims and sends one batch, returning how many it claimed.
+func deliverDue() int {
+	var due []dueDelivery
+	err := database.DB.Raw(`
+		WITH claimed AS (
+			UPDATE webhook_deliveries SET next_attempt_at = ?
+			WHERE id IN (
+				SELECT id FROM webhook_deliveries
+				WHERE status = ? AND next_attempt_at <= now()
+				ORDER BY next_attempt_at
+				LIMIT ?
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *
+		)
+		SELECT c.*, s.url, s.format, s.channel, s.secret, s.token, s.is_active as sub_active
+		FROM claimed c
+		JOIN webhook_subscriptions s ON s.id = c.subscription_id
+	`, time.Now().Add(claimTimeout), models.WebhookPending, batchSize).Scan(&due).Error
+	if err != nil {
+		log.Printf("Failed to claim webhook deliveries: %v", err)
+		return 0
+	}
+
+	for i := range due {
+		deliver(&due[i])
+	}
+	return len(due)
+}
+
+// permanentError fails a delivery without retrying it.
+type permanentError string
+
+func (e permanentError) Error() string { return string(e) }
+
+func deliver(d *dueDelivery) {
+	if !d.SubActive {
+		dead(d, 0, "subscription disabled")
+		return
+	}
+
+	var status int
+	var err error
+	switch d.Format {
+	case models.WebhookDiscord:
+		status, err = sendDiscord(d)
+	case models.WebhookSlack:
+		status, err = sendSlack(d)
+	default:
+		status, err = sendJSON(d)
+	}
+
+	var permanent permanentError
+	switch {
+	case err == nil:
+		delivered(d, status)
+	case errors.As(err, &permanent):
+		dead(d, status, err.Error())
+	default:
+		retry(d, status, err.Error())
+	}
+}
+
+// sendJSON POSTs the queued payload, signed with the subscription's secret.
+func sendJSON(d *dueDelivery) (int, error) {
+	body := []byte(d.Payload)
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, permanentError(err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-UniSpot-Event", d.EventType)
+	req.Header.Set("X-UniSpot-Delivery", strconv.FormatUint(uint64(d.ID), 10))
+	req.Header.Set("X-UniSpot-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-UniSpot-Signature", Sign(d.Secret, timestamp, body))
+
+	status, _, err := send(req)
+	return status, err
+}
+
+// send makes a request and returns the status and body, with an error for
+// anything but a 2xx.
+func send(req *http.Request) (int, []byte, error) {
+	req.Header.Set("User-Agent", "UniSpot-Webhooks/1.0")
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		reason := fmt.Sprintf("receiver returned %d", resp.StatusCode)
+		if s := strings.TrimSpace(string(body)); s != "" {
+			if len(s) > maxErrorBody {
+				s = s[:maxErrorBody]
+			}
+			reason += ": " + s
+		}
+		return resp.StatusCode, body, errors.New(reason)
+	}
+	return resp.StatusCode, body, nil
+}
+
+func delivered(d *dueDelivery, status int) {
+	if err := database.DB.Model(&models.WebhookDelivery{}).Where("id = ?", d.ID).Updates(map[string]interface{}{
+		"status":       models.WebhookDelivered,
+		"attempts":     d.Attempts + 1,
+		"last_status":  status,
+		"last_error":   "",
+		"delivered_at": time.Now(),
+	}).Error; err != nil {
+		log.Printf("Failed to update webhook delivery %d: %v", d.ID, err)
+	}
+}
+
+// retry schedules another attempt with exponential backoff from
+// WEBHOOK_RETRY_BASE, dead-lettering after WEBHOOK_MAX_ATTEMPTS.
+func retry(d *dueDelivery, status int, reason string) {
+	attempts := d.Attempts + 1
+	if attempts >= config.Int("WEBHOOK_MAX_ATTEMPTS", 8) {
+		dead(d, status, reason)
+		return
+	}
+
+	backoff := config.Duration("WEBHOOK_RETRY_BASE", 30*time.Second) * time.Duration(math.Pow(2, float64(attempts-1)))
+	if err := database.DB.Model(&models.WebhookDelivery{}).Where("id = ?", d.ID).Updates(map[string]interface{}{
+		"attempts":        attempts,
+		"last_status":     status,
+		"last_error":      reason,
+		"next_attempt_at": time.Now().Add(backoff),
+	}).Error; err != nil {
+		log.Printf("Failed to reschedule webhook delivery %d: %v", d.ID, err)
+	}
+}
+
+func dead(d *dueDelivery, status int, reason string) {
+	if err := database.DB.Model(&models.WebhookDelivery{}).Where("id = ?", d.ID).Updates(map[string]interface{}{
+		"status":      models.WebhookDead,
+		"attempts":    d.Attempts + 1,
+		"last_status": status,
+		"last_error":  reason,
+	}).Error; err != nil {
+		log.Printf("Failed to dead-letter webhook delivery %d: %v", d.ID, err)
+	}
+	log.Printf("Webhook delivery %d to %s dead-lettered: %s", d.ID, d.URL, reason)
+}
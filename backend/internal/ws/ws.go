@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/parsaabbasian/unispot/backend/internal/models"
 )
 
 const (
@@ -21,10 +23,30 @@ const (
 	pingPeriod = 45 * time.Second
 
 	// Maximum message size allowed from client.
-	maxMessageSize = 512
+	maxMessageSize = 2048
 
 	// Broadcast channel buffer — prevents blocking on slow clients.
 	broadcastBuffer = 256
+
+	// Most events a single client can watch, or subscribe to, at once.
+	maxWatchedEvents = 32
+
+	// Most categories a single client can subscribe to.
+	maxCategories = 16
+
+	// Coalesced messages are held this long; only the latest per key is sent.
+	coalesceWindow = 500 * time.Millisecond
+
+	// Per-client send buffer. A resume that would overflow it gets
+	// resync_required instead of a replay.
+	sendBuffer = 256
+
+	// Most recent messages kept for clients resuming after a reconnect.
+	replayBuffer = 1024
+
+	// How long a client connecting with ?resume= has to send subscribe/watch
+	// and resume before it is replayed to as it stands and goes live.
+	resumeWait = 5 * time.Second
 )
 
 var upgrader = websocket.Upgrader{
@@ -39,35 +61,236 @@ var upgrader = websocket.Upgrader{
 type client struct {
 	conn *websocket.Conn
 	send chan []byte
+
+	mu sync.Mutex
+	// Events whose detail view is open, set by watch/unwatch messages.
+	watching map[uint]bool
+	// Which topic messages the client wants, set by subscribe/unsubscribe.
+	filter filter
+
+	// Set from ?resume=<seq>. Live messages are held back until the client's
+	// resume message, so the replay reaches it first. Only Run touches these.
+	held       bool
+	resumeFrom uint64
+	heldUntil  time.Time
+}
+
+// clientMessage is a control message sent by the browser:
+//
+//	{"action":"watch","event_id":7}
+//	{"action":"subscribe","bbox":[west,south,east,north],"categories":["Food"],"event_ids":[7]}
+//	{"action":"unsubscribe","categories":["Food"]}
+//	{"action":"resume"}
+type clientMessage struct {
+	Action     string    `json:"action"`
+	EventID    uint      `json:"event_id"`
+	Seq        uint64    `json:"seq"`
+	BBox       []float64 `json:"bbox"`
+	Categories []string  `json:"categories"`
+	EventIDs   []uint    `json:"event_ids"`
+}
+
+// Topic is the event a message is about, so the hub can route it to the
+// clients whose subscriptions cover it.
+type Topic struct {
+	EventID  uint
+	Category string
+	Lat, Lng float64
+}
+
+// EventTopic is the topic for messages about e.
+func EventTopic(e *models.Event) Topic {
+	return Topic{EventID: e.ID, Category: e.Category, Lat: e.Latitude, Lng: e.Longitude}
+}
+
+// filter is a client's subscription. A client with an empty filter gets
+// every topic message, so clients that never subscribe see everything.
+// Otherwise it gets events it subscribed to by ID, plus events inside its
+// bbox and categories, whichever of those are set.
+type filter struct {
+	bbox       []float64 // west, south, east, north
+	categories map[string]bool
+	eventIDs   map[uint]bool
+}
+
+func (f *filter) empty() bool {
+	return f.bbox == nil && len(f.categories) == 0 && len(f.eventIDs) == 0
+}
+
+func (f *filter) matches(t *Topic) bool {
+	switch {
+	case f.empty() || f.eventIDs[t.EventID]:
+		return true
+	case f.bbox == nil && len(f.categories) == 0:
+		// Subscribed to specific events only
+		return false
+	case f.bbox != nil && (t.Lng < f.bbox[0] || t.Lat < f.bbox[1] || t.Lng > f.bbox[2] || t.Lat > f.bbox[3]):
+		return false
+	case len(f.categories) > 0 && !f.categories[t.Category]:
+		return false
+	}
+	return true
+}
+
+// validBBox reports whether b is [west, south, east, north] in degrees.
+func validBBox(b []float64) bool {
+	return len(b) == 4 &&
+		b[0] >= -180 && b[2] <= 180 && b[0] < b[2] &&
+		b[1] >= -90 && b[3] <= 90 && b[1] < b[3]
+}
+
+// resumeRequest asks the hub to replay what a reconnecting client missed.
+type resumeRequest struct {
+	client *client
+	seq    uint64
+}
+
+// outbound is a message queued for delivery. A non-zero eventID limits it
+// to clients watching that event; a topic limits it to subscribed clients.
+type outbound struct {
+	payload []byte
+	eventID uint
+	topic   *Topic
+	seq     uint64 // set by the hub when the message is sent
+}
+
+func (c *client) watch(eventID uint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.watching) < maxWatchedEvents {
+		c.watching[eventID] = true
+	}
+}
+
+func (c *client) unwatch(eventID uint) {
+	c.mu.Lock()
+	delete(c.watching, eventID)
+	c.mu.Unlock()
+}
+
+// subscribe sets the bbox and adds categories and event IDs to the filter.
+func (c *client) subscribe(msg *clientMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if validBBox(msg.BBox) {
+		c.filter.bbox = msg.BBox
+	}
+	for _, category := range msg.Categories {
+		if len(c.filter.categories) < maxCategories {
+			c.filter.categories[category] = true
+		}
+	}
+	for _, id := range msg.EventIDs {
+		if len(c.filter.eventIDs) < maxWatchedEvents {
+			c.filter.eventIDs[id] = true
+		}
+	}
+}
+
+// unsubscribe removes categories and event IDs from the filter, and the
+// bbox if one is given. With none of them it clears the whole filter.
+func (c *client) unsubscribe(msg *clientMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if msg.BBox == nil && len(msg.Categories) == 0 && len(msg.EventIDs) == 0 {
+		c.filter = newFilter()
+		return
+	}
+	if msg.BBox != nil {
+		c.filter.bbox = nil
+	}
+	for _, category := range msg.Categories {
+		delete(c.filter.categories, category)
+	}
+	for _, id := range msg.EventIDs {
+		delete(c.filter.eventIDs, id)
+	}
+}
+
+func newFilter() filter {
+	return filter{categories: make(map[string]bool), eventIDs: make(map[uint]bool)}
+}
+
+// wants reports whether a message is meant for this client.
+func (c *client) wants(message outbound) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case message.eventID != 0:
+		return c.watching[message.eventID]
+	case message.topic != nil:
+		return c.filter.matches(message.topic)
+	}
+	return true
 }
 
 // Hub maintains the set of active clients and broadcasts messages.
 type Hub struct {
 	clients    map[*client]bool
-	broadcast  chan []byte
+	broadcast  chan outbound
 	register   chan *client
 	unregister chan *client
 	mu         sync.RWMutex
+
+	// Highest number of simultaneous clients since the last TakePeak.
+	peak int
+
+	// Latest payload per key from BroadcastCoalesced, flushed every coalesceWindow.
+	coalesceMu    sync.Mutex
+	coalesced     map[string][]byte
+	coalescedKeys []string
+
+	observersMu sync.RWMutex
+	observers   []Observer
+
+	// Sequence of the last message sent, and the messages before it, indexed
+	// by seq % replayBuffer. Only Run touches these.
+	resume   chan resumeRequest
+	seq      uint64
+	firstSeq uint64
+	history  []outbound
 }
 
+// Observer is told about every BroadcastEvent, e.g. to forward it outside the
+// app. It runs on the broadcaster's goroutine, so it must not block.
+type Observer func(action string, data interface{})
+
+// NewHub creates a hub whose sequence numbers start from the current time in
+// microseconds, so they keep increasing across restarts and a client resuming
+// against a restarted server is told to resync.
 func NewHub() *Hub {
+	start := uint64(time.Now().UnixMicro())
 	return &Hub{
 		clients:    make(map[*client]bool),
-		broadcast:  make(chan []byte, broadcastBuffer),
+		broadcast:  make(chan outbound, broadcastBuffer),
 		register:   make(chan *client),
 		unregister: make(chan *client),
+		coalesced:  make(map[string][]byte),
+		resume:     make(chan resumeRequest),
+		seq:        start,
+		firstSeq:   start + 1,
+		history:    make([]outbound, replayBuffer),
 	}
 }
 
 func (h *Hub) Run() {
 	log.Printf("WebSocket Hub starting...")
+	flush := time.NewTicker(coalesceWindow)
+	defer flush.Stop()
+
 	for {
 		select {
 		case c := <-h.register:
 			h.mu.Lock()
 			h.clients[c] = true
 			count := len(h.clients)
+			if count > h.peak {
+				h.peak = count
+			}
 			h.mu.Unlock()
+			if c.held {
+				c.heldUntil = time.Now().Add(resumeWait)
+			}
 			log.Printf("Client connected. Active: %d", count)
 			h.broadcastUserCount()
 
@@ -83,31 +306,167 @@ func (h *Hub) Run() {
 			h.broadcastUserCount()
 
 		case message := <-h.broadcast:
-			h.mu.RLock()
-			clients := make([]*client, 0, len(h.clients))
-			for c := range h.clients {
-				clients = append(clients, c)
+			h.deliver(h.record(message))
+
+		case <-flush.C:
+			for _, payload := range h.takeCoalesced() {
+				h.deliver(h.record(outbound{payload: payload}))
 			}
-			h.mu.RUnlock()
-
-			for _, c := range clients {
-				select {
-				case c.send <- message:
-				default:
-					// Client send buffer full — drop and disconnect
-					h.mu.Lock()
-					if _, ok := h.clients[c]; ok {
-						delete(h.clients, c)
-						close(c.send)
-					}
-					h.mu.Unlock()
-					log.Printf("Client send buffer full, dropping connection")
-				}
+			h.releaseStale()
+
+		case req := <-h.resume:
+			h.release(req.client, req.seq)
+		}
+	}
+}
+
+// deliver fans a message out to every client it is meant for.
+func (h *Hub) deliver(message outbound) {
+	h.mu.RLock()
+	clients := make([]*client, 0, len(h.clients))
+	for c := range h.clients {
+		if !c.held && c.wants(message) {
+			clients = append(clients, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, c := range clients {
+		select {
+		case c.send <- message.payload:
+		default:
+			// Client send buffer full — drop and disconnect
+			h.mu.Lock()
+			if _, ok := h.clients[c]; ok {
+				delete(h.clients, c)
+				close(c.send)
 			}
+			h.mu.Unlock()
+			log.Printf("Client send buffer full, dropping connection")
 		}
 	}
 }
 
+// record gives a message the next sequence number and keeps it for replay.
+func (h *Hub) record(message outbound) outbound {
+	h.seq++
+	message.seq = h.seq
+	message.payload = withSeq(message.payload, h.seq)
+	h.history[h.seq%replayBuffer] = message
+	return message
+}
+
+// withSeq adds "seq" to a marshalled message object.
+func withSeq(payload []byte, seq uint64) []byte {
+	stamped := make([]byte, 0, len(payload)+24)
+	stamped = append(stamped, `{"seq":`...)
+	stamped = strconv.AppendUint(stamped, seq, 10)
+	stamped = append(stamped, ',')
+	return append(stamped, payload[1:]...)
+}
+
+// release answers a held client's resume message: it replays from seq, or
+// from the ?resume= seq if the message has none, then lets live messages
+// through. Clients that weren't held are told to resync, since live
+// messages may already have overtaken what they missed.
+func (h *Hub) release(c *client, seq uint64) {
+	h.mu.RLock()
+	_, connected := h.clients[c]
+	h.mu.RUnlock()
+	if !connected {
+		return
+	}
+	if !c.held {
+		h.sendTo(c, "resync_required", map[string]interface{}{"seq": h.seq})
+		return
+	}
+	if seq == 0 {
+		seq = c.resumeFrom
+	}
+	c.held = false
+	h.replay(c, seq)
+}
+
+// releaseStale releases held clients that haven't sent resume in time.
+func (h *Hub) releaseStale() {
+	now := time.Now()
+	h.mu.RLock()
+	var stale []*client
+	for c := range h.clients {
+		if c.held && now.After(c.heldUntil) {
+			stale = append(stale, c)
+		}
+	}
+	h.mu.RUnlock()
+	for _, c := range stale {
+		h.release(c, 0)
+	}
+}
+
+// replay sends a client the messages meant for it, under its current
+// subscription and watch list, from seq up to now, followed by "resumed".
+// If some are gone from the buffer, or there are too many to queue, it
+// sends "resync_required" and the client should refetch. Both replies carry
+// the seq to resume from next time.
+func (h *Hub) replay(c *client, seq uint64) {
+	oldest := h.firstSeq
+	if h.seq >= replayBuffer && h.seq-replayBuffer+1 > oldest {
+		oldest = h.seq - replayBuffer + 1
+	}
+
+	var missed [][]byte
+	resumable := seq <= h.seq && seq+1 >= oldest
+	if resumable {
+		for s := seq + 1; s <= h.seq; s++ {
+			if message := h.history[s%replayBuffer]; c.wants(message) {
+				missed = append(missed, message.payload)
+			}
+		}
+		// Leave room for the reply
+		resumable = len(missed) < cap(c.send)-len(c.send)
+	}
+
+	if !resumable {
+		h.sendTo(c, "resync_required", map[string]interface{}{"seq": h.seq})
+		return
+	}
+	for _, payload := range missed {
+		c.send <- payload
+	}
+	h.sendTo(c, "resumed", map[string]interface{}{"seq": h.seq, "replayed": len(missed)})
+}
+
+// sendTo sends one client a reply outside the sequence.
+func (h *Hub) sendTo(c *client, action string, data interface{}) {
+	payload, err := marshalMessage(action, data)
+	if err != nil {
+		log.Printf("WebSocket reply marshal error: %v", err)
+		return
+	}
+	select {
+	case c.send <- payload:
+	default:
+		log.Printf("Client send buffer full, dropping %s", action)
+	}
+}
+
+// takeCoalesced empties the coalesced set, returning payloads in the order
+// their keys were first seen.
+func (h *Hub) takeCoalesced() [][]byte {
+	h.coalesceMu.Lock()
+	defer h.coalesceMu.Unlock()
+	if len(h.coalescedKeys) == 0 {
+		return nil
+	}
+	payloads := make([][]byte, len(h.coalescedKeys))
+	for i, key := range h.coalescedKeys {
+		payloads[i] = h.coalesced[key]
+		delete(h.coalesced, key)
+	}
+	h.coalescedKeys = h.coalescedKeys[:0]
+	return payloads
+}
+
 func (h *Hub) broadcastUserCount() {
 	h.mu.RLock()
 	count := len(h.clients)
@@ -115,19 +474,94 @@ func (h *Hub) broadcastUserCount() {
 	h.BroadcastEvent("user_count", map[string]interface{}{"count": count})
 }
 
-// BroadcastEvent sends a typed action+data message to all connected clients.
+// ClientCount returns the number of connected clients.
+func (h *Hub) ClientCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// TakePeak returns the highest concurrent client count since the previous
+// call and starts a new window from the current count.
+func (h *Hub) TakePeak() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	peak := h.peak
+	h.peak = len(h.clients)
+	return peak
+}
+
+// BroadcastEvent sends a typed action+data message to all connected clients
+// and tells every observer about it.
 func (h *Hub) BroadcastEvent(action string, data interface{}) {
-	payload, err := json.Marshal(map[string]interface{}{
+	h.enqueue(outbound{}, action, data)
+	h.notify(action, data)
+}
+
+// BroadcastTopic sends a typed action+data message about one event to the
+// clients whose subscriptions cover topic, and tells every observer about it.
+func (h *Hub) BroadcastTopic(topic Topic, action string, data interface{}) {
+	h.enqueue(outbound{topic: &topic}, action, data)
+	h.notify(action, data)
+}
+
+func (h *Hub) notify(action string, data interface{}) {
+	h.observersMu.RLock()
+	observers := h.observers
+	h.observersMu.RUnlock()
+	for _, observe := range observers {
+		observe(action, data)
+	}
+}
+
+// Observe registers fn to be called for every BroadcastEvent.
+func (h *Hub) Observe(fn Observer) {
+	h.observersMu.Lock()
+	h.observers = append(h.observers, fn)
+	h.observersMu.Unlock()
+}
+
+// BroadcastToEventWatchers sends a typed action+data message only to clients
+// that have sent a watch message for eventID.
+func (h *Hub) BroadcastToEventWatchers(eventID uint, action string, data interface{}) {
+	h.enqueue(outbound{eventID: eventID}, action, data)
+}
+
+// BroadcastCoalesced sends a typed action+data message to all connected
+// clients within coalesceWindow. Messages with the same key that arrive in
+// the meantime replace it, so data should carry full state, not a delta.
+func (h *Hub) BroadcastCoalesced(key, action string, data interface{}) {
+	payload, err := marshalMessage(action, data)
+	if err != nil {
+		log.Printf("BroadcastCoalesced marshal error: %v", err)
+		return
+	}
+
+	h.coalesceMu.Lock()
+	if _, ok := h.coalesced[key]; !ok {
+		h.coalescedKeys = append(h.coalescedKeys, key)
+	}
+	h.coalesced[key] = payload
+	h.coalesceMu.Unlock()
+}
+
+func marshalMessage(action string, data interface{}) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
 		"action": action,
 		"data":   data,
 	})
+}
+
+func (h *Hub) enqueue(message outbound, action string, data interface{}) {
+	payload, err := marshalMessage(action, data)
 	if err != nil {
 		log.Printf("BroadcastEvent marshal error: %v", err)
 		return
 	}
+	message.payload = payload
 
 	select {
-	case h.broadcast <- payload:
+	case h.broadcast <- message:
 	default:
 		log.Printf("WARNING: broadcast channel full, message dropped")
 	}
@@ -135,7 +569,10 @@ func (h *Hub) BroadcastEvent(action string, data interface{}) {
 
 var GlobalHub = NewHub()
 
-// HandleConnections upgrades HTTP to WebSocket and registers the client.
+// HandleConnections upgrades HTTP to WebSocket and registers the client. A
+// client reconnecting with ?resume=<seq> gets nothing live until it has sent
+// any subscribe/watch messages and then resume, which replays what it missed
+// since seq.
 func HandleConnections(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -144,8 +581,17 @@ func HandleConnections(w http.ResponseWriter, r *http.Request) {
 	}
 
 	c := &client{
-		conn: conn,
-		send: make(chan []byte, 64),
+		conn:     conn,
+		send:     make(chan []byte, sendBuffer),
+		watching: make(map[uint]bool),
+		filter:   newFilter(),
+	}
+
+	if v := r.URL.Query().Get("resume"); v != "" {
+		if seq, err := strconv.ParseUint(v, 10, 64); err == nil {
+			c.held = true
+			c.resumeFrom = seq
+		}
 	}
 
 	GlobalHub.register <- c
@@ -155,8 +601,8 @@ func HandleConnections(w http.ResponseWriter, r *http.Request) {
 	c.readPump()
 }
 
-// readPump reads from the WebSocket and handles pong messages.
-// It also detects dead connections via read deadline.
+// readPump handles watch/unwatch, subscribe/unsubscribe and resume messages
+// and pongs, and detects dead connections via the read deadline.
 func (c *client) readPump() {
 	defer func() {
 		GlobalHub.unregister <- c
@@ -171,13 +617,30 @@ func (c *client) readPump() {
 	})
 
 	for {
-		_, _, err := c.conn.ReadMessage()
+		_, data, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket unexpected close: %v", err)
 			}
 			break
 		}
+
+		var msg clientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		switch {
+		case msg.Action == "watch" && msg.EventID != 0:
+			c.watch(msg.EventID)
+		case msg.Action == "unwatch" && msg.EventID != 0:
+			c.unwatch(msg.EventID)
+		case msg.Action == "subscribe":
+			c.subscribe(&msg)
+		case msg.Action == "unsubscribe":
+			c.unsubscribe(&msg)
+		case msg.Action == "resume":
+			GlobalHub.resume <- resumeRequest{client: c, seq: msg.Seq}
+		}
 	}
 }
 
@@ -6,6 +6,7 @@ import EventForm from './components/EventForm';
 import LandingPage from './components/LandingPage';
 import EventDetailOverlay from './components/EventDetailOverlay';
 import AdminDashboard from './components/AdminDashboard';
+import { supabase } from './supabaseClient';
 import axios from 'axios';
 import { Menu, X, Plus, Check } from 'lucide-react';
 
@@ -51,6 +52,26 @@ function App() {
   useEffect(() => {
     localStorage.setItem('unispot_theme', isDarkMode ? 'dark' : 'light');
   }, [isDarkMode]);
+
+  // The signed-in student comes from their Supabase session, which the API verifies
+  useEffect(() => {
+    const applySession = (session: any) => {
+      if (!session?.user?.email) {
+        setCurrentUser(null);
+        localStorage.removeItem('unispot_user');
+        return;
+      }
+      const userData = {
+        name: session.user.user_metadata?.name || session.user.email.split('@')[0],
+        email: session.user.email,
+      };
+      setCurrentUser(userData);
+      localStorage.setItem('unispot_user', JSON.stringify(userData));
+    };
+    supabase.auth.getSession().then(({ data }: any) => applySession(data.session));
+    const { data: { subscription } } = supabase.auth.onAuthStateChange((_event: string, session: any) => applySession(session));
+    return () => subscription.unsubscribe();
+  }, []);
   const [isSelectingLocation, setIsSelectingLocation] = useState(false);
   const [isSidebarOpen, setIsSidebarOpen] = useState(false);
   const [isSidebarCollapsed, setIsSidebarCollapsed] = useState(false);
@@ -58,6 +79,7 @@ function App() {
   const [notification, setNotification] = useState<{ type: 'new' | 'verify' | 'delete' | 'error', title: string, category: string, userName?: string } | null>(null);
   const reconnectAttempts = useRef(0);
   const reconnectTimer = useRef<ReturnType<typeof setTimeout> | undefined>(undefined);
+  const lastSeq = useRef<number | null>(null); // last hub message seen, for resuming after a reconnect
 
   const calculateDistance = (lat1: number, lon1: number, lat2: number, lon2: number) => {
     const R = 6371; // Radius of the earth in km
@@ -137,11 +159,29 @@ function App() {
 
     let socket: WebSocket;
     const connectWS = () => {
-      socket = new WebSocket(wsUrl);
+      // Ask for whatever was broadcast while we were disconnected; the hub holds
+      // live messages until the resume below so the replay comes first
+      const resuming = lastSeq.current !== null;
+      socket = new WebSocket(resuming ? `${wsUrl}?resume=${lastSeq.current}` : wsUrl);
+
+      socket.onopen = () => {
+        if (resuming) {
+          socket.send(JSON.stringify({ action: 'resume' }));
+        }
+      };
 
       socket.onmessage = (event) => {
         const message = JSON.parse(event.data);
-        if (message.action === 'new_event') {
+        if (typeof message.seq === 'number') {
+          lastSeq.current = message.seq;
+        }
+        if (message.action === 'resumed') {
+          lastSeq.current = message.data.seq;
+        } else if (message.action === 'resync_required') {
+          // Too much was missed to replay — start over from a fresh fetch
+          lastSeq.current = message.data.seq;
+          fetchEvents();
+        } else if (message.action === 'new_event') {
           setEvents(prev => {
             if (prev.find(e => e.id === message.data.id)) return prev;
             return [message.data, ...prev];
@@ -156,6 +196,8 @@ function App() {
                 ? {
                   ...e,
                   verified_count: message.data.verified_count,
+                  confidence: message.data.confidence ?? e.confidence,
+                  last_verified_at: message.data.last_verified_at ?? e.last_verified_at,
                   verifiers: [...(e.verifiers || []), message.data.user_name]
                 }
                 : e
@@ -199,9 +241,22 @@ function App() {
             setNotification({ type: 'verify', title: message.data.title ?? updated.title, category: message.data.category ?? updated.category });
             setTimeout(() => setNotification(null), 3000);
           }
+        } else if (message.action === 'merge_event') {
+          // Duplicates were folded into one event — drop them and follow the survivor
+          const mergedIds: number[] = message.data.merged_ids;
+          setEvents(prev => {
+            const next = prev
+              .filter(e => !mergedIds.includes(e.id))
+              .map(e => e.id === message.data.id ? { ...e, verified_count: message.data.verified_count } : e);
+            const survivor = next.find(e => e.id === message.data.id) ?? null;
+            setSelectedDetailEvent(selected => selected && mergedIds.includes(selected.id) ? survivor : selected);
+            return next;
+          });
+        } else if (message.action === 'reaction') {
+          setEvents(prev => prev.map(e => e.id === message.data.id ? { ...e, reactions: message.data.counts } : e));
         } else if (message.action === 'user_count') {
           setActiveUserCount(message.data.count);
-        } else if (message.action === 'delete_event') {
+        } else if (message.action === 'delete_event' || message.action === 'hide_event') {
           setEvents(prev => {
             const eventToRemove = prev.find(e => e.id === message.data.id);
             if (eventToRemove) {
@@ -270,9 +325,16 @@ function App() {
   const handleVerifyEvent = async (id: number) => {
     try {
       const apiUrl = import.meta.env.VITE_API_URL || 'http://localhost:8081';
+      // Verifications are only accepted from people near the event
+      const position = await new Promise<GeolocationPosition>((resolve, reject) =>
+        navigator.geolocation.getCurrentPosition(resolve, reject, { enableHighAccuracy: true, timeout: 10000 })
+      );
       await axios.post(`${apiUrl}/api/events/${id}/verify`, {
         user_name: currentUser?.name,
-        user_email: currentUser?.email
+        user_email: currentUser?.email,
+        lat: position.coords.latitude,
+        lng: position.coords.longitude,
+        accuracy: position.coords.accuracy
       });
 
       // Update local state immediately
@@ -342,11 +404,6 @@ function App() {
   if (!showMap || !currentUser) {
     return (
       <LandingPage
-        onEnter={(userData) => {
-          setCurrentUser(userData);
-          localStorage.setItem('unispot_user', JSON.stringify(userData));
-          window.location.hash = 'map';
-        }}
         isDarkMode={isDarkMode}
         onToggleTheme={() => setIsDarkMode(!isDarkMode)}
       />
@@ -398,6 +455,7 @@ function App() {
             recentActivity={recentActivity}
             currentUser={currentUser}
             onLogout={() => {
+              supabase.auth.signOut();
               setCurrentUser(null);
               localStorage.removeItem('unispot_user');
               window.location.hash = '';
@@ -1,5 +1,6 @@
 import React, { useState } from 'react';
 import { X, Clock, Tag, MapPin, AlignLeft, ShieldCheck, AlertCircle, ChevronDown, Check, ChevronUp, Utensils, BookOpen, Users, Cpu, Music, Dumbbell, ShieldAlert, ShoppingBag } from 'lucide-react';
+import { supabase } from '../supabaseClient';
 
 interface EventFormProps {
     lat: number;
@@ -94,9 +95,13 @@ const EventForm: React.FC<EventFormProps> = ({ lat, lng, onClose, onCreated, cur
         setSubmitting(true);
         try {
             const apiUrl = import.meta.env.VITE_API_URL || 'http://localhost:8081';
+            const { data: { session } } = await supabase.auth.getSession();
             const response = await fetch(`${apiUrl}/api/events`, {
                 method: 'POST',
-                headers: { 'Content-Type': 'application/json' },
+                headers: {
+                    'Content-Type': 'application/json',
+                    ...(session ? { Authorization: `Bearer ${session.access_token}` } : {}),
+                },
                 body: JSON.stringify({
                     title,
                     description,
@@ -105,10 +110,16 @@ const EventForm: React.FC<EventFormProps> = ({ lat, lng, onClose, onCreated, cur
                     lng,
                     duration_hours: durationHours + (durationMinutes / 60),
                     creator_name: currentUser?.name || 'Anonymous',
-                    creator_email: currentUser?.email || '',
                 }),
             });
             if (response.ok) {
+                // Only the creator gets the organizer token; keep it for check-ins and extensions
+                const created = await response.json();
+                if (created.organizer_token) {
+                    const tokens = JSON.parse(localStorage.getItem('unispot_organizer_tokens') || '{}');
+                    tokens[created.id] = created.organizer_token;
+                    localStorage.setItem('unispot_organizer_tokens', JSON.stringify(tokens));
+                }
                 setIsSuccess(true);
                 onCreated();
                 setTimeout(() => {
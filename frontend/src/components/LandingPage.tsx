@@ -1,21 +1,23 @@
 import React, { useEffect, useState } from 'react';
-import { ShieldCheck, X, MapPin, User, ArrowRight, CheckCircle2, Navigation } from 'lucide-react';
+import { ShieldCheck, X, MapPin, User, Mail, ArrowRight, CheckCircle2, Navigation } from 'lucide-react';
+import { supabase } from '../supabaseClient';
 
 interface LandingPageProps {
-    onEnter: (userData: { name: string, email: string }) => void;
     isDarkMode: boolean;
     onToggleTheme: () => void;
 }
 
-const LandingPage: React.FC<LandingPageProps> = ({ onEnter, isDarkMode, onToggleTheme }) => {
+const LandingPage: React.FC<LandingPageProps> = ({ isDarkMode, onToggleTheme }) => {
     const [isVisible, setIsVisible] = useState(true);
     const [lastScrollY, setLastScrollY] = useState(0);
     const [showLocationGuide, setShowLocationGuide] = useState(false);
     const [showAuthForm, setShowAuthForm] = useState(false);
     const [name, setName] = useState('');
+    const [email, setEmail] = useState('');
+    const [linkSent, setLinkSent] = useState(false);
     const [error, setError] = useState('');
 
-    const handleLaunch = () => {
+    const handleLaunch = async () => {
         if (!showAuthForm) {
             setShowAuthForm(true);
             return;
@@ -26,11 +28,25 @@ const LandingPage: React.FC<LandingPageProps> = ({ onEnter, isDarkMode, onToggle
             return;
         }
 
-        // Simple entry without email verification
-        onEnter({
-            name: name.trim(),
-            email: `${name.trim().toLowerCase().replace(/\s+/g, '')}@student.yorku.ca`
+        if (!/^[^\s@]+@[^\s@]+$/.test(email.trim())) {
+            setError('Please enter your email address');
+            return;
+        }
+
+        // The API only trusts a verified session, so sign in with a magic link;
+        // App picks the session up when the student comes back from their inbox
+        const { error: signInError } = await supabase.auth.signInWithOtp({
+            email: email.trim(),
+            options: {
+                data: { name: name.trim() },
+                emailRedirectTo: `${window.location.origin}/#map`,
+            },
         });
+        if (signInError) {
+            setError(signInError.message);
+            return;
+        }
+        setLinkSent(true);
     };
 
     useEffect(() => {
@@ -131,7 +147,7 @@ const LandingPage: React.FC<LandingPageProps> = ({ onEnter, isDarkMode, onToggle
                                     Welcome to UniSpot
                                 </h2>
                                 <p className="text-white/50 text-base sm:text-lg font-medium mb-12 max-w-sm mx-auto leading-relaxed">
-                                    Your portal to York University starts here. <br />Enter a nickname to begin.
+                                    Your portal to York University starts here. <br />Pick a nickname and we'll email you a sign-in link.
                                 </p>
 
                                 <div className="space-y-6 mb-10 text-left">
@@ -151,11 +167,33 @@ const LandingPage: React.FC<LandingPageProps> = ({ onEnter, isDarkMode, onToggle
                                             className={`w-full bg-white/5 border-[1.5px] rounded-[2rem] py-6 pl-16 pr-6 text-lg font-semibold transition-all placeholder:text-white/10 text-white focus:outline-none focus:bg-white/10 ${error ? 'border-red-500/50 shadow-[0_0_15px_rgba(239,68,68,0.1)]' : 'border-white/5 focus:border-primary/50 focus:ring-4 focus:ring-primary/10'}`}
                                             autoFocus
                                         />
+                                    </div>
+                                    <div className="relative group/input">
+                                        <div className="absolute inset-y-0 left-6 flex items-center pointer-events-none">
+                                            <Mail className="w-6 h-6 text-white/20 group-focus-within/input:text-primary transition-colors" />
+                                        </div>
+                                        <input
+                                            type="email"
+                                            placeholder="you@my.yorku.ca"
+                                            value={email}
+                                            onChange={(e) => {
+                                                setEmail(e.target.value);
+                                                setError('');
+                                                setLinkSent(false);
+                                            }}
+                                            onKeyDown={(e) => e.key === 'Enter' && handleLaunch()}
+                                            className={`w-full bg-white/5 border-[1.5px] rounded-[2rem] py-6 pl-16 pr-6 text-lg font-semibold transition-all placeholder:text-white/10 text-white focus:outline-none focus:bg-white/10 ${error ? 'border-red-500/50 shadow-[0_0_15px_rgba(239,68,68,0.1)]' : 'border-white/5 focus:border-primary/50 focus:ring-4 focus:ring-primary/10'}`}
+                                        />
                                         {error && (
                                             <div className="absolute -bottom-7 left-6 animate-in slide-in-from-top-1">
                                                 <p className="text-red-400 text-xs font-bold uppercase tracking-widest">{error}</p>
                                             </div>
                                         )}
+                                        {linkSent && !error && (
+                                            <div className="absolute -bottom-7 left-6 animate-in slide-in-from-top-1">
+                                                <p className="text-emerald-400 text-xs font-bold uppercase tracking-widest">Check your inbox for the sign-in link</p>
+                                            </div>
+                                        )}
                                     </div>
                                 </div>
 
@@ -12,6 +12,7 @@ export const supabase = (supabaseUrl && supabaseAnonKey)
             signInWithOtp: async () => ({ error: new Error("Supabase URL or Anon Key missing in Environment Variables.") }),
             onAuthStateChange: () => ({ data: { subscription: { unsubscribe: () => { } } } }),
             getSession: async () => ({ data: { session: null } }),
+            signOut: async () => ({ error: null }),
         }
     } as any;
 
@@ -6,6 +6,9 @@ export interface Event {
     lat: number;
     lng: number;
     verified_count: number;
+    confidence?: number;
+    last_verified_at?: string | null;
+    reactions?: Record<string, number>;
     duration_hours?: number;
     start_time: string;
     end_time: string;
@@ -122,12 +122,14 @@ async function scrapeEvents() {
                 lat: lat,
                 lng: lng,
                 duration_hours: 24, // keep it up for a day
-                creator_name: item.author + " (Scraped)",
-                creator_email: "scraper_bot@unispot.ca" // Dedicated tag for scraped data
+                creator_name: item.author + " (Scraped)"
             };
 
             try {
-                await axios.post(YORK_API_URL, eventPayload);
+                // SCRAPER_TOKEN posts these as scraper_bot@unispot.ca, which builds its own reputation
+                await axios.post(YORK_API_URL, eventPayload, {
+                    headers: { Authorization: `Bearer ${process.env.SCRAPER_TOKEN || ''}` }
+                });
                 console.log(`[OK] Pushed: ${eventPayload.title} (${category})`);
                 successCount++;
             } catch (err) {